@@ -2,32 +2,151 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type contextKey string
 
 const UserContextKey = contextKey("user")
 
+// AccessTokenTTL is how long an access JWT issued by IssueTokenPair or
+// RefreshToken remains valid. It is kept short so that a leaked access token
+// is only useful briefly; refresh tokens carry the long-lived session
+// instead. RevokeAllForUser does not wait for this TTL: it bumps the user's
+// token_version, which ValidateToken checks against the "ver" claim.
+const AccessTokenTTL = 15 * time.Minute
+
+// RefreshTokenTTL is how long an opaque refresh token remains valid.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// ErrTokenExpired is returned by ValidateToken when the token parsed
+// correctly but its exp claim has passed, so callers can distinguish an
+// expired-but-refreshable token from one that is simply invalid.
+var ErrTokenExpired = errors.New("token expired")
+
+// LegacyFullScope is assigned to tokens that predate the scopes claim (i.e.
+// any token issued before this change) so they keep working, with the
+// access of the old is_service boolean, until they expire.
+const LegacyFullScope = "legacy:full"
+
+// ErrMissingScope is returned by RequireScope when the authenticated user's
+// token doesn't carry the requested scope.
+var ErrMissingScope = errors.New("missing required scope")
+
+// sqlDB is the subset of *db.DB that auth needs for refresh token storage.
+// Declared locally (rather than importing the db package) to avoid coupling
+// auth to the concrete database driver.
+type sqlDB interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 type User struct {
 	ID        string
 	Email     string
 	Name      string
-	IsService bool // true if this is a service account (bot, etc.)
+	IsService bool     // true if this is a service account (bot, etc.); logging/audit only, not an authorization check
+	Scopes    []string // authorization scopes, e.g. "entries:write"; see RequireScope
+}
+
+// HasScope reports whether the user's token grants scope, either directly
+// or via the LegacyFullScope grandfather clause.
+func (u *User) HasScope(scope string) bool {
+	for _, s := range u.Scopes {
+		if s == scope || s == LegacyFullScope {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenPair is the result of issuing or refreshing a session: a short-lived
+// access JWT plus the opaque refresh token backing it.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
 }
 
 type Service struct {
-	jwtSecret string
+	keyProvider KeyProvider
+	db          sqlDB
+	store       TokenStore
+
+	// connectorsMu guards connectors, the social-login state added in
+	// external.go. Service keeps no record of in-flight logins: LoginURL
+	// hands the caller a fresh state to bind to the initiating browser
+	// (e.g. a signed cookie), and HandleCallback only trusts whatever the
+	// caller attests that binding said, so state can't collide or be
+	// replayed across logins the way a server-global map would allow.
+	connectorsMu sync.RWMutex
+	connectors   map[string]ExternalConnector
+
+	// passwordHasher is the PasswordHasher HashPassword uses and
+	// CheckPassword compares stored hashes against for needsRehash; nil
+	// falls back to defaultPasswordHasher (see password.go).
+	passwordHasher PasswordHasher
 }
 
+// NewService creates a Service backed by a single static HS256 secret. This
+// is the legacy signing path, kept for backwards compatibility with
+// existing deployments; new code should prefer NewServiceWithKeyProvider so
+// tokens can be verified without sharing the signing secret.
 func NewService(jwtSecret string) *Service {
-	return &Service{jwtSecret: jwtSecret}
+	return &Service{keyProvider: newStaticHMACProvider(jwtSecret)}
+}
+
+// NewServiceWithDB creates a Service that also persists refresh tokens, as
+// required by IssueTokenPair/RefreshToken/RevokeToken/RevokeAllForUser. Its
+// TokenStore is a SQLTokenStore backed by the same database; use
+// NewServiceWithTokenStore to plug in a different one (e.g. MemTokenStore).
+// database may be nil (e.g. in tests that don't exercise refresh tokens),
+// in which case store is left nil too, matching the s.store != nil guard
+// in ValidateToken/IssueTokenPair.
+func NewServiceWithDB(jwtSecret string, database sqlDB) *Service {
+	s := &Service{keyProvider: newStaticHMACProvider(jwtSecret), db: database}
+	if database != nil {
+		s.store = NewSQLTokenStore(database)
+	}
+	return s
+}
+
+// NewServiceWithKeyProvider creates a Service that signs and verifies JWTs
+// through keyProvider (e.g. a DBKeyProvider managing RS256/EdDSA keys),
+// rather than a single shared HS256 secret. Its TokenStore is a
+// SQLTokenStore backed by the same database; use NewServiceWithTokenStore
+// to plug in a different one (e.g. MemTokenStore). database may be nil,
+// in which case store is left nil too, matching the s.store != nil guard
+// in ValidateToken/IssueTokenPair.
+func NewServiceWithKeyProvider(keyProvider KeyProvider, database sqlDB) *Service {
+	s := &Service{keyProvider: keyProvider, db: database}
+	if database != nil {
+		s.store = NewSQLTokenStore(database)
+	}
+	return s
+}
+
+// NewServiceWithTokenStore creates a Service with an explicit TokenStore,
+// for callers that want refresh tokens and the access-token denylist kept
+// somewhere other than a SQLTokenStore over database (e.g. a MemTokenStore
+// for tests or a single-process deployment). database is still used for
+// token_version lookups, which belong to the users table rather than the
+// TokenStore.
+func NewServiceWithTokenStore(keyProvider KeyProvider, database sqlDB, store TokenStore) *Service {
+	return &Service{keyProvider: keyProvider, db: database, store: store}
 }
 
 // GenerateToken creates a JWT token for a user
@@ -37,29 +156,248 @@ func (s *Service) GenerateToken(userID, email, name string) (string, error) {
 
 // GenerateTokenWithFlags creates a JWT token with custom flags
 func (s *Service) GenerateTokenWithFlags(userID, email, name string, isService bool) (string, error) {
+	return s.GenerateTokenWithScopes(userID, email, name, isService, nil)
+}
+
+// GenerateTokenWithScopes creates a JWT token carrying an explicit scopes
+// claim, e.g. ["entries:write", "files:read"] for a service token. A nil
+// scopes is encoded as a null claim, which ValidateToken treats like an
+// absent one and grants LegacyFullScope; a non-nil empty scopes is encoded
+// as [] and leaves the token with no scopes.
+func (s *Service) GenerateTokenWithScopes(userID, email, name string, isService bool, scopes []string) (string, error) {
+	jti, err := generateJTI()
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
 		"user_id":    userID,
 		"email":      email,
 		"name":       name,
 		"is_service": isService,
+		"scopes":     scopes,
+		"jti":        jti,
 		"exp":        time.Now().Add(24 * 7 * time.Hour).Unix(), // 7 days
 		"iat":        time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return s.sign(claims)
 }
 
-// ValidateToken validates and parses a JWT token
-func (s *Service) ValidateToken(tokenString string) (*User, error) {
+// sign encodes claims with the key provider's current active key, stamping
+// its kid in the JWT header so ValidateToken can pick the matching
+// verification key even after RotateKeys runs.
+func (s *Service) sign(claims jwt.MapClaims) (string, error) {
+	key, err := s.keyProvider.ActiveKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to load signing key: %w", err)
+	}
+
+	token := jwt.NewWithClaims(key.SigningMethod, claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.SignKey)
+}
+
+// IssueTokenPair mints a short-lived access JWT (stamped with the user's
+// current token_version) alongside a new opaque refresh token persisted in
+// the configured TokenStore.
+func (s *Service) IssueTokenPair(ctx context.Context, userID, email, name string, userAgent, ip string) (*TokenPair, error) {
+	if s.db == nil || s.store == nil {
+		return nil, fmt.Errorf("auth: service not configured with a database")
+	}
+
+	version, err := s.tokenVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.generateAccessToken(ctx, userID, email, name, false, version)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, expiresAt, err := s.storeRefreshToken(ctx, userID, userAgent, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// RefreshToken exchanges a valid, unrevoked refresh token for a fresh
+// TokenPair, rotating the refresh token in the process: refreshToken is
+// consumed and cannot be used again, so a stolen-then-replayed refresh
+// token is rejected with ErrRefreshTokenReused the moment its legitimate
+// owner refreshes first.
+func (s *Service) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if s.db == nil || s.store == nil {
+		return nil, fmt.Errorf("auth: service not configured with a database")
+	}
+
+	raw, expiresAt, err := generateRefreshTokenValue()
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.store.Rotate(ctx, hashRefreshToken(refreshToken), hashRefreshToken(raw), expiresAt, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var email, name string
+	if err := s.db.QueryRowContext(ctx, `SELECT email, name FROM users WHERE id = $1`, userID).Scan(&email, &name); err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	version, err := s.tokenVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.generateAccessToken(ctx, userID, email, name, false, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: raw, ExpiresAt: expiresAt}, nil
+}
+
+// RevokeToken revokes a single refresh token, ending that session.
+func (s *Service) RevokeToken(ctx context.Context, refreshToken string) error {
+	if s.store == nil {
+		return fmt.Errorf("auth: service not configured with a database")
+	}
+	return s.store.Revoke(ctx, hashRefreshToken(refreshToken))
+}
+
+// RevokeAllForUser revokes every outstanding refresh token for a user and
+// bumps their token_version, so any access token minted before this call
+// (which carries the prior version in its "ver" claim) is rejected by
+// ValidateToken immediately, rather than only once it naturally expires.
+func (s *Service) RevokeAllForUser(ctx context.Context, userID string) error {
+	if s.db == nil || s.store == nil {
+		return fmt.Errorf("auth: service not configured with a database")
+	}
+
+	if err := s.store.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to bump token version: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) tokenVersion(ctx context.Context, userID string) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT token_version FROM users WHERE id = $1`, userID).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load token version: %w", err)
+	}
+	return version, nil
+}
+
+func (s *Service) storeRefreshToken(ctx context.Context, userID, userAgent, ip string) (string, time.Time, error) {
+	token, expiresAt, err := generateRefreshTokenValue()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := s.store.Store(ctx, hashRefreshToken(token), userID, expiresAt, userAgent, ip); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// generateRefreshTokenValue creates a new opaque refresh token and its
+// expiry, without persisting it; callers store it via TokenStore.Store
+// (a fresh session) or TokenStore.Rotate (a refresh).
+func generateRefreshTokenValue() (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), time.Now().Add(RefreshTokenTTL), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateJTI returns a random, URL-safe access-token ID for the jti claim,
+// letting ValidateToken kill an individual token via the TokenStore
+// denylist before its exp.
+func generateJTI() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate jti: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (s *Service) generateAccessToken(ctx context.Context, userID, email, name string, isService bool, tokenVersion int) (string, error) {
+	jti, err := generateJTI()
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id":    userID,
+		"email":      email,
+		"name":       name,
+		"is_service": isService,
+		"ver":        tokenVersion,
+		"jti":        jti,
+		"exp":        time.Now().Add(AccessTokenTTL).Unix(),
+		"iat":        time.Now().Unix(),
+	}
+
+	return s.sign(claims)
+}
+
+// ValidateToken validates and parses a JWT token, rejecting one whose jti
+// has been revoked via Denylist (e.g. by a future admin "kill this one
+// session" action) even though it hasn't reached its exp yet. The denylist
+// check is skipped when the Service has no TokenStore, the same as tokens
+// that predate the jti claim entirely. It also rejects a token whose "ver"
+// claim no longer matches the user's current token_version, so
+// RevokeAllForUser invalidates every access token it was called before,
+// not just the refresh tokens it revokes directly; that check is skipped
+// when the Service has no database, the same as tokens that predate the
+// ver claim entirely.
+func (s *Service) ValidateToken(ctx context.Context, tokenString string) (*User, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+
+		var key *SigningKey
+		var err error
+		if kid != "" {
+			key, err = s.keyProvider.KeyByKid(kid)
+		} else {
+			// Tokens signed before kid stamping was introduced: fall back
+			// to the current active key.
+			key, err = s.keyProvider.ActiveKey()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unknown signing key: %w", err)
+		}
+		if key.SigningMethod.Alg() != token.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(s.jwtSecret), nil
+		return key.VerifyKey, nil
 	})
 
 	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
 
@@ -79,27 +417,60 @@ func (s *Service) ValidateToken(tokenString string) (*User, error) {
 			isService = isServiceClaim
 		}
 
+		if jti, ok := claims["jti"].(string); ok && jti != "" && s.store != nil {
+			denylisted, err := s.store.IsDenylisted(ctx, jti)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check access token denylist: %w", err)
+			}
+			if denylisted {
+				return nil, fmt.Errorf("token has been revoked")
+			}
+		}
+
+		if verClaim, ok := claims["ver"].(float64); ok && s.db != nil {
+			currentVersion, err := s.tokenVersion(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			if int(verClaim) != currentVersion {
+				return nil, fmt.Errorf("token has been revoked")
+			}
+		}
+
 		return &User{
 			ID:        userID,
 			Email:     email,
 			Name:      name,
 			IsService: isService,
+			Scopes:    scopesFromClaims(claims),
 		}, nil
 	}
 
 	return nil, fmt.Errorf("invalid token")
 }
 
-// HashPassword hashes a password using bcrypt
-func (s *Service) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
-}
+// scopesFromClaims reads the "scopes" claim. Tokens that predate the scopes
+// claim entirely (the key is absent), or whose claim is null or not a list,
+// are granted LegacyFullScope so they keep working until they expire; a
+// token with an explicit empty scopes list is left with no scopes.
+func scopesFromClaims(claims jwt.MapClaims) []string {
+	raw, present := claims["scopes"]
+	if !present || raw == nil {
+		return []string{LegacyFullScope}
+	}
 
-// CheckPassword compares a password with a hash
-func (s *Service) CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	list, ok := raw.([]interface{})
+	if !ok {
+		return []string{LegacyFullScope}
+	}
+
+	scopes := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
 }
 
 // Middleware extracts and validates JWT token from Authorization header
@@ -126,8 +497,13 @@ func Middleware(authService *Service) func(http.Handler) http.Handler {
 				return
 			}
 
-			user, err := authService.ValidateToken(parts[1])
+			user, err := authService.ValidateToken(r.Context(), parts[1])
 			if err != nil {
+				if errors.Is(err, ErrTokenExpired) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="token expired"`)
+					http.Error(w, "Token expired", http.StatusUnauthorized)
+					return
+				}
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
@@ -139,6 +515,32 @@ func Middleware(authService *Service) func(http.Handler) http.Handler {
 	}
 }
 
+// KeyProvider returns the Service's signing/verification key set, e.g. to
+// serve it at GET /.well-known/jwks.json.
+func (s *Service) KeyProvider() KeyProvider {
+	return s.keyProvider
+}
+
+// JWKS builds the public JWKS document for the Service's current key set,
+// for handlers that would rather call the Service directly than thread a
+// KeyProvider through to the package-level JWKS function themselves.
+func (s *Service) JWKS() (map[string]interface{}, error) {
+	return JWKS(s.keyProvider)
+}
+
+// RotateKeys generates a new active signing key and schedules the previous
+// one to retire after grace, so tokens it already signed keep validating
+// until then. It only works when the Service was constructed with a
+// DBKeyProvider (NewServiceWithKeyProvider); a static HS256 secret has
+// nothing to rotate to.
+func (s *Service) RotateKeys(ctx context.Context, grace time.Duration) (*SigningKey, error) {
+	provider, ok := s.keyProvider.(*DBKeyProvider)
+	if !ok {
+		return nil, fmt.Errorf("auth: key rotation requires a DBKeyProvider, got %T", s.keyProvider)
+	}
+	return provider.RotateKeys(ctx, grace)
+}
+
 // GetUserFromContext extracts user from context
 func GetUserFromContext(ctx context.Context) (*User, error) {
 	user, ok := ctx.Value(UserContextKey).(*User)
@@ -156,3 +558,17 @@ func RequireAuth(ctx context.Context) (*User, error) {
 	}
 	return user, nil
 }
+
+// RequireScope ensures the authenticated user's token carries scope. It is
+// meant to be called from resolvers directly, or from the generated
+// implementation of the GraphQL `@requireScope(scope: "...")` directive.
+func RequireScope(ctx context.Context, scope string) (*User, error) {
+	user, err := RequireAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasScope(scope) {
+		return nil, fmt.Errorf("%w: %s", ErrMissingScope, scope)
+	}
+	return user, nil
+}
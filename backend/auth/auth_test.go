@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -14,8 +16,13 @@ func TestNewService(t *testing.T) {
 	if service == nil {
 		t.Fatal("NewService returned nil")
 	}
-	if service.jwtSecret != testSecret {
-		t.Errorf("Expected jwtSecret to be %s, got %s", testSecret, service.jwtSecret)
+
+	key, err := service.keyProvider.ActiveKey()
+	if err != nil {
+		t.Fatalf("ActiveKey failed: %v", err)
+	}
+	if string(key.SignKey.([]byte)) != testSecret {
+		t.Errorf("Expected active key secret to be %s, got %s", testSecret, key.SignKey)
 	}
 }
 
@@ -136,7 +143,7 @@ func TestValidateToken(t *testing.T) {
 	}
 	
 	// Validate it
-	user, err := service.ValidateToken(token)
+	user, err := service.ValidateToken(context.Background(), token)
 	if err != nil {
 		t.Fatalf("ValidateToken failed: %v", err)
 	}
@@ -163,7 +170,7 @@ func TestValidateTokenWithServiceFlag(t *testing.T) {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
 	
-	user, err := service.ValidateToken(token)
+	user, err := service.ValidateToken(context.Background(), token)
 	if err != nil {
 		t.Fatalf("ValidateToken failed: %v", err)
 	}
@@ -204,7 +211,7 @@ func TestValidateTokenInvalid(t *testing.T) {
 	
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := service.ValidateToken(tt.token)
+			_, err := service.ValidateToken(context.Background(), tt.token)
 			if err == nil {
 				t.Error("Expected validation to fail, but it succeeded")
 			}
@@ -231,7 +238,7 @@ func TestValidateTokenExpired(t *testing.T) {
 		t.Fatalf("Failed to create expired token: %v", err)
 	}
 	
-	_, err = service.ValidateToken(tokenString)
+	_, err = service.ValidateToken(context.Background(), tokenString)
 	if err == nil {
 		t.Error("Expected validation to fail for expired token")
 	}
@@ -278,7 +285,7 @@ func TestValidateTokenMissingClaims(t *testing.T) {
 				t.Fatalf("Failed to create token: %v", err)
 			}
 			
-			_, err = service.ValidateToken(tokenString)
+			_, err = service.ValidateToken(context.Background(), tokenString)
 			if err == nil {
 				t.Error("Expected validation to fail for token with missing claims")
 			}
@@ -321,21 +328,137 @@ func TestCheckPassword(t *testing.T) {
 	}
 	
 	// Test correct password
-	if !service.CheckPassword(password, hash) {
+	ok, _, err := service.CheckPassword(password, hash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if !ok {
 		t.Error("CheckPassword failed for correct password")
 	}
-	
+
 	// Test wrong password
-	if service.CheckPassword("wrong-password", hash) {
+	ok, _, err = service.CheckPassword("wrong-password", hash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if ok {
 		t.Error("CheckPassword succeeded for wrong password")
 	}
-	
+
 	// Test empty password
-	if service.CheckPassword("", hash) {
+	ok, _, err = service.CheckPassword("", hash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if ok {
 		t.Error("CheckPassword succeeded for empty password")
 	}
 }
 
+func TestGenerateTokenWithScopes(t *testing.T) {
+	service := NewService(testSecret)
+
+	token, err := service.GenerateTokenWithScopes("svc-1", "svc@example.com", "Service", true, []string{"entries:write", "files:read"})
+	if err != nil {
+		t.Fatalf("GenerateTokenWithScopes failed: %v", err)
+	}
+
+	user, err := service.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if !user.HasScope("entries:write") {
+		t.Error("Expected user to have entries:write scope")
+	}
+	if user.HasScope("directories:delete") {
+		t.Error("Expected user not to have an ungranted scope")
+	}
+}
+
+func TestValidateTokenWithoutScopesClaimGrandfathersLegacyFull(t *testing.T) {
+	service := NewService(testSecret)
+
+	// GenerateToken passes nil scopes, which is encoded as a null "scopes"
+	// claim; ValidateToken treats that the same as a token minted before the
+	// scopes claim existed.
+	token, err := service.GenerateToken("user-1", "user@example.com", "User")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	user, err := service.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if !user.HasScope("anything:at-all") {
+		t.Error("Expected a pre-scopes token to be grandfathered with legacy:full")
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	service := NewService(testSecret)
+
+	token, err := service.GenerateTokenWithScopes("svc-1", "svc@example.com", "Service", true, []string{"entries:write"})
+	if err != nil {
+		t.Fatalf("GenerateTokenWithScopes failed: %v", err)
+	}
+	user, err := service.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), UserContextKey, user)
+
+	if _, err := RequireScope(ctx, "entries:write"); err != nil {
+		t.Errorf("Expected RequireScope to succeed for granted scope, got %v", err)
+	}
+	if _, err := RequireScope(ctx, "files:delete"); !errors.Is(err, ErrMissingScope) {
+		t.Errorf("Expected ErrMissingScope for ungranted scope, got %v", err)
+	}
+}
+
+func TestValidateTokenExpiredReturnsSentinel(t *testing.T) {
+	service := NewService(testSecret)
+
+	claims := jwt.MapClaims{
+		"user_id":    "user-expired",
+		"email":      "expired@example.com",
+		"name":       "Expired User",
+		"is_service": false,
+		"exp":        time.Now().Add(-1 * time.Hour).Unix(),
+		"iat":        time.Now().Add(-2 * time.Hour).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("Failed to create expired token: %v", err)
+	}
+
+	_, err = service.ValidateToken(context.Background(), tokenString)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestRefreshTokenMethodsRequireDB(t *testing.T) {
+	service := NewService(testSecret)
+	ctx := context.Background()
+
+	if _, err := service.IssueTokenPair(ctx, "user-1", "user@example.com", "User", "test-agent", "127.0.0.1"); err == nil {
+		t.Error("Expected IssueTokenPair to fail without a configured database")
+	}
+	if _, err := service.RefreshToken(ctx, "some-token"); err == nil {
+		t.Error("Expected RefreshToken to fail without a configured database")
+	}
+	if err := service.RevokeToken(ctx, "some-token"); err == nil {
+		t.Error("Expected RevokeToken to fail without a configured database")
+	}
+	if err := service.RevokeAllForUser(ctx, "user-1"); err == nil {
+		t.Error("Expected RevokeAllForUser to fail without a configured database")
+	}
+}
+
 func TestHashPasswordDifferentHashes(t *testing.T) {
 	service := NewService(testSecret)
 	
@@ -357,10 +480,12 @@ func TestHashPasswordDifferentHashes(t *testing.T) {
 	}
 	
 	// But both should validate correctly
-	if !service.CheckPassword(password, hash1) {
-		t.Error("First hash doesn't validate")
+	ok, _, err := service.CheckPassword(password, hash1)
+	if err != nil || !ok {
+		t.Errorf("First hash doesn't validate: ok=%v err=%v", ok, err)
 	}
-	if !service.CheckPassword(password, hash2) {
-		t.Error("Second hash doesn't validate")
+	ok, _, err = service.CheckPassword(password, hash2)
+	if err != nil || !ok {
+		t.Errorf("Second hash doesn't validate: ok=%v err=%v", ok, err)
 	}
 }
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+)
+
+// ExternalIdentity is the identity an ExternalConnector resolves a code
+// into: the provider-specific subject plus whatever profile fields the
+// provider returns.
+type ExternalIdentity struct {
+	Provider string
+	Subject  string // the provider's stable user ID, e.g. GitHub's numeric id
+	Email    string
+	Name     string
+}
+
+// ExternalConnector is one OIDC/OAuth2 social login provider (GitHub,
+// Google, a generic OIDC issuer, ...). Implementations wrap the provider's
+// oauth2.Config and, for OIDC, its ID-token verifier.
+type ExternalConnector interface {
+	// AuthCodeURL returns the provider's authorization endpoint URL the user
+	// should be redirected to, embedding state for CSRF protection.
+	AuthCodeURL(state string) string
+	// Exchange redeems an authorization code for the user's identity.
+	Exchange(ctx context.Context, code string) (*ExternalIdentity, error)
+}
+
+// RegisterConnector makes connector available under id (e.g. "github",
+// "google"), for LoginURL and HandleCallback.
+func (s *Service) RegisterConnector(id string, connector ExternalConnector) {
+	s.connectorsMu.Lock()
+	defer s.connectorsMu.Unlock()
+	if s.connectors == nil {
+		s.connectors = make(map[string]ExternalConnector)
+	}
+	s.connectors[id] = connector
+}
+
+func (s *Service) connector(id string) (ExternalConnector, error) {
+	s.connectorsMu.RLock()
+	defer s.connectorsMu.RUnlock()
+	connector, ok := s.connectors[id]
+	if !ok {
+		return nil, fmt.Errorf("auth: unknown external connector %q", id)
+	}
+	return connector, nil
+}
+
+// LoginURL returns the URL to redirect the user to for connector id,
+// along with a freshly generated state value. Service keeps no record of
+// the state itself: the caller must bind it to the browser that's
+// starting this login (e.g. a short-lived signed cookie) and pass it
+// back to HandleCallback as expectedState, so a callback can only
+// succeed for the browser that actually initiated it.
+func (s *Service) LoginURL(id string) (loginURL, state string, err error) {
+	connector, err := s.connector(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err = randomState()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate OAuth2 state: %w", err)
+	}
+
+	return connector.AuthCodeURL(state), state, nil
+}
+
+// randomState returns a URL-safe, cryptographically random OAuth2 state
+// value.
+func randomState() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HandleCallback redeems code for connector id's identity, verifies state
+// matches expectedState -- the value the caller bound to the browser
+// that started this login when it called LoginURL, e.g. read back from a
+// signed cookie -- and upserts a user linked by (provider, subject): an
+// existing link signs that user in, an unlinked identity whose email
+// matches an existing user links to it, and anything else creates a new
+// user. It returns a JWT minted the same way GenerateTokenWithFlags
+// always has, not a full IssueTokenPair session, since a social login is
+// just another way to authenticate the request that issued it.
+func (s *Service) HandleCallback(ctx context.Context, id, code, state, expectedState string) (string, error) {
+	if s.db == nil {
+		return "", fmt.Errorf("auth: service not configured with a database")
+	}
+
+	connector, err := s.connector(id)
+	if err != nil {
+		return "", err
+	}
+
+	if expectedState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(expectedState)) != 1 {
+		return "", fmt.Errorf("auth: mismatched OAuth2 state for connector %q", id)
+	}
+
+	identity, err := connector.Exchange(ctx, code)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	userID, name, err := s.upsertExternalUser(ctx, identity)
+	if err != nil {
+		return "", err
+	}
+
+	return s.GenerateTokenWithFlags(userID, identity.Email, name, false)
+}
+
+// upsertExternalUser resolves identity to a user ID, linking or creating a
+// user as needed, and returns that user's ID and name.
+func (s *Service) upsertExternalUser(ctx context.Context, identity *ExternalIdentity) (userID, name string, err error) {
+	err = s.db.QueryRowContext(ctx,
+		`SELECT u.id, u.name FROM external_identities ei JOIN users u ON u.id = ei.user_id
+		 WHERE ei.provider = $1 AND ei.subject = $2`,
+		identity.Provider, identity.Subject,
+	).Scan(&userID, &name)
+	if err == nil {
+		return userID, name, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", "", fmt.Errorf("failed to look up external identity: %w", err)
+	}
+
+	// No existing link for this (provider, subject); link to a user with a
+	// matching email if one exists, otherwise create a new one.
+	err = s.db.QueryRowContext(ctx, `SELECT id, name FROM users WHERE email = $1`, identity.Email).
+		Scan(&userID, &name)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", fmt.Errorf("failed to look up user by email: %w", err)
+	}
+	if err == sql.ErrNoRows {
+		name = identity.Name
+		err = s.db.QueryRowContext(ctx,
+			`INSERT INTO users (email, name) VALUES ($1, $2) RETURNING id`,
+			identity.Email, name,
+		).Scan(&userID)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create user: %w", err)
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO external_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)`,
+		userID, identity.Provider, identity.Subject, identity.Email,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to link external identity: %w", err)
+	}
+
+	return userID, name, nil
+}
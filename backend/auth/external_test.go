@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// fakeSQLDB satisfies sqlDB just well enough to give a Service a non-nil db
+// for tests that never actually reach a query (e.g. because the state
+// check or connector lookup fails first); its methods are not expected to
+// be called.
+type fakeSQLDB struct{}
+
+func (f *fakeSQLDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	panic("fakeSQLDB: unexpected query: " + query)
+}
+
+func (f *fakeSQLDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	panic("fakeSQLDB: unexpected query: " + query)
+}
+
+func (f *fakeSQLDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	panic("fakeSQLDB: unexpected exec: " + query)
+}
+
+type mockConnector struct {
+	authCodeURL string
+	identity    *ExternalIdentity
+	exchangeErr error
+	exchanged   string // the code Exchange was called with, for assertions
+}
+
+func (c *mockConnector) AuthCodeURL(state string) string {
+	return fmt.Sprintf("%s?state=%s", c.authCodeURL, state)
+}
+
+func (c *mockConnector) Exchange(ctx context.Context, code string) (*ExternalIdentity, error) {
+	c.exchanged = code
+	if c.exchangeErr != nil {
+		return nil, c.exchangeErr
+	}
+	return c.identity, nil
+}
+
+func TestLoginURLUnknownConnector(t *testing.T) {
+	service := NewService(testSecret)
+
+	if _, _, err := service.LoginURL("github"); err == nil {
+		t.Error("Expected LoginURL to fail for an unregistered connector")
+	}
+}
+
+func TestLoginURLReturnsConnectorAuthCodeURL(t *testing.T) {
+	service := NewService(testSecret)
+	connector := &mockConnector{authCodeURL: "https://github.com/login/oauth/authorize"}
+	service.RegisterConnector("github", connector)
+
+	url, state, err := service.LoginURL("github")
+	if err != nil {
+		t.Fatalf("LoginURL failed: %v", err)
+	}
+	if state == "" {
+		t.Fatal("Expected LoginURL to return a non-empty state")
+	}
+	if url != fmt.Sprintf("https://github.com/login/oauth/authorize?state=%s", state) {
+		t.Errorf("Unexpected login URL: %s", url)
+	}
+}
+
+func TestLoginURLStatesAreUnpredictable(t *testing.T) {
+	service := NewService(testSecret)
+	connector := &mockConnector{authCodeURL: "https://github.com/login/oauth/authorize"}
+	service.RegisterConnector("github", connector)
+
+	_, stateA, err := service.LoginURL("github")
+	if err != nil {
+		t.Fatalf("LoginURL failed: %v", err)
+	}
+	_, stateB, err := service.LoginURL("github")
+	if err != nil {
+		t.Fatalf("LoginURL failed: %v", err)
+	}
+	if stateA == stateB {
+		t.Error("Expected successive LoginURL calls to generate distinct states")
+	}
+}
+
+func TestHandleCallbackRequiresDB(t *testing.T) {
+	service := NewService(testSecret)
+	connector := &mockConnector{authCodeURL: "https://github.com/login/oauth/authorize"}
+	service.RegisterConnector("github", connector)
+
+	_, state, err := service.LoginURL("github")
+	if err != nil {
+		t.Fatalf("LoginURL failed: %v", err)
+	}
+
+	if _, err := service.HandleCallback(context.Background(), "github", "some-code", state, state); err == nil {
+		t.Error("Expected HandleCallback to fail without a configured database")
+	}
+}
+
+func TestHandleCallbackRejectsMismatchedState(t *testing.T) {
+	service := NewServiceWithDB(testSecret, &fakeSQLDB{})
+	connector := &mockConnector{authCodeURL: "https://github.com/login/oauth/authorize"}
+	service.RegisterConnector("github", connector)
+
+	_, state, err := service.LoginURL("github")
+	if err != nil {
+		t.Fatalf("LoginURL failed: %v", err)
+	}
+
+	if _, err := service.HandleCallback(context.Background(), "github", "some-code", state, "wrong-state"); err == nil {
+		t.Error("Expected HandleCallback to reject a mismatched state")
+	}
+	if connector.exchanged != "" {
+		t.Error("Expected Exchange to never be called when state doesn't match")
+	}
+}
+
+func TestHandleCallbackRejectsMissingExpectedState(t *testing.T) {
+	service := NewServiceWithDB(testSecret, &fakeSQLDB{})
+	connector := &mockConnector{authCodeURL: "https://github.com/login/oauth/authorize"}
+	service.RegisterConnector("github", connector)
+
+	_, state, err := service.LoginURL("github")
+	if err != nil {
+		t.Fatalf("LoginURL failed: %v", err)
+	}
+
+	if _, err := service.HandleCallback(context.Background(), "github", "some-code", state, ""); err == nil {
+		t.Error("Expected HandleCallback to reject a callback with no expected state bound to it")
+	}
+}
+
+func TestHandleCallbackUnknownConnector(t *testing.T) {
+	service := NewServiceWithDB(testSecret, &fakeSQLDB{})
+
+	if _, err := service.HandleCallback(context.Background(), "github", "some-code", "state-123", "state-123"); err == nil {
+		t.Error("Expected HandleCallback to fail for an unregistered connector")
+	}
+}
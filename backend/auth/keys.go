@@ -0,0 +1,365 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one entry in a KeyProvider's key set: the jwt.SigningMethod
+// plus the key material needed to sign (SignKey) or verify (VerifyKey)
+// tokens under a given `kid`.
+type SigningKey struct {
+	Kid           string
+	Algorithm     string // "HS256", "RS256", or "EdDSA"
+	SigningMethod jwt.SigningMethod
+	SignKey       interface{}
+	VerifyKey     interface{}
+}
+
+// KeyProvider returns the key currently used to sign new tokens plus the
+// full set of keys that should still be trusted for verification (so a key
+// rotated out during its grace period keeps validating outstanding tokens).
+type KeyProvider interface {
+	ActiveKey() (*SigningKey, error)
+	VerificationKeys() ([]*SigningKey, error)
+	KeyByKid(kid string) (*SigningKey, error)
+}
+
+// staticHMACProvider is the backwards-compatible KeyProvider used when a
+// Service is constructed with a raw HS256 secret instead of a KeyProvider.
+type staticHMACProvider struct {
+	key *SigningKey
+}
+
+func newStaticHMACProvider(secret string) *staticHMACProvider {
+	return &staticHMACProvider{
+		key: &SigningKey{
+			Kid:           "legacy-hs256",
+			Algorithm:     "HS256",
+			SigningMethod: jwt.SigningMethodHS256,
+			SignKey:       []byte(secret),
+			VerifyKey:     []byte(secret),
+		},
+	}
+}
+
+func (p *staticHMACProvider) ActiveKey() (*SigningKey, error) { return p.key, nil }
+
+func (p *staticHMACProvider) VerificationKeys() ([]*SigningKey, error) {
+	return []*SigningKey{p.key}, nil
+}
+
+func (p *staticHMACProvider) KeyByKid(kid string) (*SigningKey, error) {
+	if kid == p.key.Kid || kid == "" {
+		return p.key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// DBKeyProvider manages a rolling set of RS256/EdDSA keys persisted in the
+// signing_keys table. On first use it loads the current active key (the
+// newest row with retired_at IS NULL), generating and persisting one if
+// there is no such row. Private keys are envelope-encrypted with masterKey
+// (AES-256-GCM) before they ever reach the database, so a read-only leak of
+// the signing_keys table (a backup, a replica, a misconfigured grant) isn't
+// enough to forge tokens; see encryptPrivatePEM/decryptPrivatePEM.
+type DBKeyProvider struct {
+	db        sqlDB
+	algorithm string // algorithm used for newly generated keys: RS256 or EdDSA
+	masterKey []byte // AES-256 key encrypting private_pem_encrypted at rest
+
+	mu     sync.RWMutex
+	active *SigningKey
+	// verification holds every key that should still validate a token,
+	// i.e. the active key plus any retired key whose grace period hasn't
+	// elapsed yet.
+	verification map[string]*SigningKey
+}
+
+// NewDBKeyProvider loads (or, on first boot, generates and persists) the
+// signing key set for algorithm ("RS256" or "EdDSA"). masterKey must be
+// exactly 32 bytes; it encrypts every private key before it's written to
+// signing_keys and decrypts it again on load, so it must stay stable across
+// restarts and be kept out of the database it protects (e.g. a KMS-backed
+// secret, not an env var alongside DATABASE_URL in the same place).
+func NewDBKeyProvider(ctx context.Context, database sqlDB, algorithm string, masterKey []byte) (*DBKeyProvider, error) {
+	if len(masterKey) != 32 {
+		return nil, fmt.Errorf("auth: signing key master key must be 32 bytes, got %d", len(masterKey))
+	}
+	p := &DBKeyProvider{db: database, algorithm: algorithm, masterKey: masterKey}
+	if err := p.reload(ctx); err != nil {
+		return nil, err
+	}
+	if p.active == nil {
+		if _, err := p.RotateKeys(ctx, 0); err != nil {
+			return nil, fmt.Errorf("failed to generate initial signing key: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *DBKeyProvider) ActiveKey() (*SigningKey, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.active == nil {
+		return nil, fmt.Errorf("auth: no active signing key loaded")
+	}
+	return p.active, nil
+}
+
+func (p *DBKeyProvider) VerificationKeys() ([]*SigningKey, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	keys := make([]*SigningKey, 0, len(p.verification))
+	for _, k := range p.verification {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (p *DBKeyProvider) KeyByKid(kid string) (*SigningKey, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, ok := p.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown or retired key id %q", kid)
+	}
+	return key, nil
+}
+
+// RotateKeys generates a new active key and schedules the previous active
+// key to retire after grace (tokens it already signed keep validating
+// until then). grace of zero retires the previous key immediately.
+func (p *DBKeyProvider) RotateKeys(ctx context.Context, grace time.Duration) (*SigningKey, error) {
+	newKey, publicPEM, privatePEM, err := generateKeyPair(p.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	previous := p.active
+	p.mu.Unlock()
+
+	if previous != nil {
+		retiredAt := time.Now().Add(grace)
+		if _, err := p.db.ExecContext(ctx,
+			`UPDATE signing_keys SET retired_at = $1 WHERE kid = $2 AND retired_at IS NULL`,
+			retiredAt, previous.Kid,
+		); err != nil {
+			return nil, fmt.Errorf("failed to retire previous signing key: %w", err)
+		}
+	}
+
+	encryptedPEM, err := encryptPrivatePEM(p.masterKey, privatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt new signing key: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx,
+		`INSERT INTO signing_keys (kid, algorithm, public_pem, private_pem_encrypted) VALUES ($1, $2, $3, $4)`,
+		newKey.Kid, newKey.Algorithm, publicPEM, encryptedPEM,
+	); err != nil {
+		return nil, fmt.Errorf("failed to persist new signing key: %w", err)
+	}
+
+	if err := p.reload(ctx); err != nil {
+		return nil, err
+	}
+	return newKey, nil
+}
+
+// reload refreshes the active key and verification set from the database.
+func (p *DBKeyProvider) reload(ctx context.Context) error {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT kid, algorithm, public_pem, private_pem_encrypted, retired_at FROM signing_keys
+		 WHERE retired_at IS NULL OR retired_at > NOW()
+		 ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	verification := make(map[string]*SigningKey)
+	var active *SigningKey
+	for rows.Next() {
+		var kid, algorithm, publicPEM, encryptedPEM string
+		var retiredAt sql.NullTime
+		if err := rows.Scan(&kid, &algorithm, &publicPEM, &encryptedPEM, &retiredAt); err != nil {
+			return fmt.Errorf("failed to scan signing key: %w", err)
+		}
+
+		privatePEM, err := decryptPrivatePEM(p.masterKey, encryptedPEM)
+		if err != nil {
+			return fmt.Errorf("auth: failed to decrypt private key for kid %q: %w", kid, err)
+		}
+
+		key, err := decodeKeyPair(kid, algorithm, publicPEM, privatePEM)
+		if err != nil {
+			return err
+		}
+		verification[kid] = key
+		if !retiredAt.Valid && active == nil {
+			active = key
+		}
+	}
+
+	p.mu.Lock()
+	p.verification = verification
+	p.active = active
+	p.mu.Unlock()
+	return nil
+}
+
+func generateKeyPair(algorithm string) (key *SigningKey, publicPEM, privatePEM string, err error) {
+	kid := fmt.Sprintf("%s-%d", algorithm, time.Now().UnixNano())
+
+	switch algorithm {
+	case "RS256":
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, "", "", err
+		}
+		pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+		if err != nil {
+			return nil, "", "", err
+		}
+		privatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}))
+		publicPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}))
+		return &SigningKey{
+			Kid:           kid,
+			Algorithm:     algorithm,
+			SigningMethod: jwt.SigningMethodRS256,
+			SignKey:       priv,
+			VerifyKey:     &priv.PublicKey,
+		}, publicPEM, privatePEM, nil
+
+	case "EdDSA":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, "", "", err
+		}
+		pubBytes, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, "", "", err
+		}
+		privatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}))
+		publicPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}))
+		return &SigningKey{
+			Kid:           kid,
+			Algorithm:     algorithm,
+			SigningMethod: jwt.SigningMethodEdDSA,
+			SignKey:       priv,
+			VerifyKey:     pub,
+		}, publicPEM, privatePEM, nil
+
+	default:
+		return nil, "", "", fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+// encryptPrivatePEM envelope-encrypts a PEM-encoded private key with
+// AES-256-GCM under masterKey, returning a base64 string safe to store in
+// signing_keys.private_pem_encrypted. The nonce is prepended to the
+// ciphertext so decryptPrivatePEM doesn't need it stored separately.
+func encryptPrivatePEM(masterKey []byte, plaintextPEM string) (string, error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return "", fmt.Errorf("auth: invalid signing key master key: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("auth: failed to generate nonce: %w", err)
+	}
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintextPEM), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decryptPrivatePEM reverses encryptPrivatePEM.
+func decryptPrivatePEM(masterKey []byte, encoded string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("auth: malformed encrypted private key: %w", err)
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return "", fmt.Errorf("auth: invalid signing key master key: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", fmt.Errorf("auth: encrypted private key is truncated")
+	}
+	nonce, ciphertext := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("auth: failed to decrypt private key, wrong master key?: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func decodeKeyPair(kid, algorithm, publicPEM, privatePEM string) (*SigningKey, error) {
+	privBlock, _ := pem.Decode([]byte(privatePEM))
+	if privBlock == nil {
+		return nil, fmt.Errorf("auth: malformed private key PEM for kid %q", kid)
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(privBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to parse private key for kid %q: %w", kid, err)
+	}
+
+	pubBlock, _ := pem.Decode([]byte(publicPEM))
+	if pubBlock == nil {
+		return nil, fmt.Errorf("auth: malformed public key PEM for kid %q", kid)
+	}
+	pub, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to parse public key for kid %q: %w", kid, err)
+	}
+
+	var method jwt.SigningMethod
+	switch algorithm {
+	case "RS256":
+		method = jwt.SigningMethodRS256
+	case "EdDSA":
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("auth: unsupported signing algorithm %q for kid %q", algorithm, kid)
+	}
+
+	return &SigningKey{
+		Kid:           kid,
+		Algorithm:     algorithm,
+		SigningMethod: method,
+		SignKey:       priv,
+		VerifyKey:     pub,
+	}, nil
+}
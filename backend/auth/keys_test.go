@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type fakeKeyProvider struct {
+	active *SigningKey
+	byKid  map[string]*SigningKey
+}
+
+func (p *fakeKeyProvider) ActiveKey() (*SigningKey, error) { return p.active, nil }
+
+func (p *fakeKeyProvider) VerificationKeys() ([]*SigningKey, error) {
+	keys := make([]*SigningKey, 0, len(p.byKid))
+	for _, k := range p.byKid {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (p *fakeKeyProvider) KeyByKid(kid string) (*SigningKey, error) {
+	key, ok := p.byKid[kid]
+	if !ok {
+		return nil, errKeyNotFound(kid)
+	}
+	return key, nil
+}
+
+type errKeyNotFound string
+
+func (e errKeyNotFound) Error() string { return "unknown key id: " + string(e) }
+
+func TestGenerateKeyPairRS256RoundTrip(t *testing.T) {
+	key, _, _, err := generateKeyPair("RS256")
+	if err != nil {
+		t.Fatalf("generateKeyPair failed: %v", err)
+	}
+
+	provider := &fakeKeyProvider{active: key, byKid: map[string]*SigningKey{key.Kid: key}}
+	service := NewServiceWithKeyProvider(provider, nil)
+
+	token, err := service.GenerateToken("user-1", "user@example.com", "User")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	user, err := service.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if user.ID != "user-1" {
+		t.Errorf("Expected user-1, got %s", user.ID)
+	}
+}
+
+func TestRotateKeysOldTokenStillValidates(t *testing.T) {
+	keyA, _, _, err := generateKeyPair("EdDSA")
+	if err != nil {
+		t.Fatalf("generateKeyPair failed: %v", err)
+	}
+
+	provider := &fakeKeyProvider{active: keyA, byKid: map[string]*SigningKey{keyA.Kid: keyA}}
+	service := NewServiceWithKeyProvider(provider, nil)
+
+	oldToken, err := service.GenerateToken("user-1", "user@example.com", "User")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	// Rotate: key B becomes active, key A stays around for verification.
+	keyB, _, _, err := generateKeyPair("EdDSA")
+	if err != nil {
+		t.Fatalf("generateKeyPair failed: %v", err)
+	}
+	provider.active = keyB
+	provider.byKid[keyB.Kid] = keyB
+
+	if _, err := service.ValidateToken(context.Background(), oldToken); err != nil {
+		t.Errorf("Expected token signed with retired key A to still validate, got %v", err)
+	}
+
+	newToken, err := service.GenerateToken("user-2", "user2@example.com", "User Two")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified failed: %v", err)
+	}
+	if parsed.Header["kid"] != keyB.Kid {
+		t.Errorf("Expected new tokens to carry kid %s, got %v", keyB.Kid, parsed.Header["kid"])
+	}
+}
+
+func TestJWKSExcludesSymmetricKeys(t *testing.T) {
+	provider := newStaticHMACProvider(testSecret)
+
+	doc, err := JWKS(provider)
+	if err != nil {
+		t.Fatalf("JWKS failed: %v", err)
+	}
+	keys, ok := doc["keys"].([]jwk)
+	if !ok {
+		t.Fatalf("Expected keys field to be []jwk, got %T", doc["keys"])
+	}
+	if len(keys) != 0 {
+		t.Errorf("Expected HS256-only provider to publish no JWKS keys, got %d", len(keys))
+	}
+}
+
+func TestServiceJWKSDelegatesToKeyProvider(t *testing.T) {
+	key, _, _, err := generateKeyPair("RS256")
+	if err != nil {
+		t.Fatalf("generateKeyPair failed: %v", err)
+	}
+	provider := &fakeKeyProvider{active: key, byKid: map[string]*SigningKey{key.Kid: key}}
+	service := NewServiceWithKeyProvider(provider, nil)
+
+	doc, err := service.JWKS()
+	if err != nil {
+		t.Fatalf("Service.JWKS failed: %v", err)
+	}
+	keys := doc["keys"].([]jwk)
+	if len(keys) != 1 || keys[0].Kid != key.Kid {
+		t.Errorf("Unexpected JWKS document: %+v", doc)
+	}
+}
+
+func TestServiceRotateKeysRequiresDBKeyProvider(t *testing.T) {
+	service := NewService(testSecret)
+
+	if _, err := service.RotateKeys(context.Background(), 0); err == nil {
+		t.Error("Expected RotateKeys to fail for a Service without a DBKeyProvider")
+	}
+}
+
+func TestEncryptDecryptPrivatePEMRoundTrip(t *testing.T) {
+	masterKey := make([]byte, 32)
+	for i := range masterKey {
+		masterKey[i] = byte(i)
+	}
+
+	_, _, privatePEM, err := generateKeyPair("RS256")
+	if err != nil {
+		t.Fatalf("generateKeyPair failed: %v", err)
+	}
+
+	encrypted, err := encryptPrivatePEM(masterKey, privatePEM)
+	if err != nil {
+		t.Fatalf("encryptPrivatePEM failed: %v", err)
+	}
+	if strings.Contains(encrypted, "PRIVATE KEY") {
+		t.Error("Expected encrypted output not to contain the plaintext PEM")
+	}
+
+	decrypted, err := decryptPrivatePEM(masterKey, encrypted)
+	if err != nil {
+		t.Fatalf("decryptPrivatePEM failed: %v", err)
+	}
+	if decrypted != privatePEM {
+		t.Error("Expected decrypted PEM to match the original plaintext")
+	}
+}
+
+func TestDecryptPrivatePEMRejectsWrongMasterKey(t *testing.T) {
+	masterKey := make([]byte, 32)
+	wrongKey := make([]byte, 32)
+	wrongKey[0] = 1
+
+	_, _, privatePEM, err := generateKeyPair("RS256")
+	if err != nil {
+		t.Fatalf("generateKeyPair failed: %v", err)
+	}
+
+	encrypted, err := encryptPrivatePEM(masterKey, privatePEM)
+	if err != nil {
+		t.Fatalf("encryptPrivatePEM failed: %v", err)
+	}
+
+	if _, err := decryptPrivatePEM(wrongKey, encrypted); err == nil {
+		t.Error("Expected decryption with the wrong master key to fail")
+	}
+}
+
+func TestNewDBKeyProviderRejectsWrongMasterKeyLength(t *testing.T) {
+	if _, err := NewDBKeyProvider(context.Background(), nil, "RS256", []byte("too-short")); err == nil {
+		t.Error("Expected NewDBKeyProvider to reject a master key that isn't 32 bytes")
+	}
+}
+
+func TestJWKSIncludesRS256PublicKey(t *testing.T) {
+	key, _, _, err := generateKeyPair("RS256")
+	if err != nil {
+		t.Fatalf("generateKeyPair failed: %v", err)
+	}
+	provider := &fakeKeyProvider{active: key, byKid: map[string]*SigningKey{key.Kid: key}}
+
+	doc, err := JWKS(provider)
+	if err != nil {
+		t.Fatalf("JWKS failed: %v", err)
+	}
+	keys := doc["keys"].([]jwk)
+	if len(keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(keys))
+	}
+	if keys[0].Kty != "RSA" || keys[0].Kid != key.Kid {
+		t.Errorf("Unexpected JWK: %+v", keys[0])
+	}
+}
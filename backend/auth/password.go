@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes and verifies passwords under one algorithm. Hashes
+// it produces are self-describing PHC-style strings (e.g. "$2a$14$..." for
+// bcrypt, "$argon2id$v=19$m=...,t=...,p=...$salt$hash" for Argon2id), so
+// CheckPassword can pick the right hasher to verify against purely from
+// the stored hash, independent of which hasher the Service is currently
+// configured with.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) (bool, error)
+	// prefix is the PHC-style tag this hasher's hashes start with, used by
+	// CheckPassword to tell whether a stored hash came from a different
+	// algorithm than the configured hasher.
+	prefix() string
+	// weaker reports whether hash (already known to carry this hasher's
+	// prefix) was produced with lower cost/params than this hasher is
+	// currently configured to use.
+	weaker(hash string) bool
+}
+
+// defaultPasswordHasher is used by any Service that hasn't been given one
+// via SetPasswordHasher, matching the bcrypt cost this package always used
+// before PasswordHasher existed.
+var defaultPasswordHasher PasswordHasher = NewBcryptHasher(14)
+
+// SetPasswordHasher configures the PasswordHasher used by HashPassword and
+// as the "currently configured" algorithm CheckPassword compares stored
+// hashes against to decide needsRehash.
+func (s *Service) SetPasswordHasher(hasher PasswordHasher) {
+	s.passwordHasher = hasher
+}
+
+func (s *Service) currentPasswordHasher() PasswordHasher {
+	if s.passwordHasher != nil {
+		return s.passwordHasher
+	}
+	return defaultPasswordHasher
+}
+
+// HashPassword hashes password with the Service's configured PasswordHasher
+// (bcrypt cost 14 by default).
+func (s *Service) HashPassword(password string) (string, error) {
+	return s.currentPasswordHasher().Hash(password)
+}
+
+// HashPasswordWithParams hashes password with hasher directly instead of
+// the Service's configured default, for one-off cost tuning (e.g. a lower
+// bcrypt cost in tests, or Argon2id params sized for a specific deployment).
+func (s *Service) HashPasswordWithParams(password string, hasher PasswordHasher) (string, error) {
+	return hasher.Hash(password)
+}
+
+// CheckPassword verifies password against hash, auto-detecting the hashing
+// algorithm from hash's PHC-style prefix. needsRehash is true when hash was
+// produced by a weaker algorithm, or lower cost/params, than the Service is
+// currently configured to use, so a caller can transparently re-hash and
+// store the upgraded value on a successful login.
+func (s *Service) CheckPassword(password, hash string) (ok bool, needsRehash bool, err error) {
+	hasher, err := hasherForHash(hash)
+	if err != nil {
+		return false, false, err
+	}
+
+	ok, err = hasher.Verify(password, hash)
+	if err != nil || !ok {
+		return ok, false, err
+	}
+
+	current := s.currentPasswordHasher()
+	if hasher.prefix() != current.prefix() {
+		// Argon2id is the stronger of the two algorithms: a bcrypt hash
+		// under an Argon2id policy needs upgrading, the reverse does not.
+		return true, current.prefix() == defaultArgon2Hasher.prefix(), nil
+	}
+	return true, current.weaker(hash), nil
+}
+
+// hasherForHash returns the PasswordHasher that produced hash, detected
+// from its PHC-style prefix.
+func hasherForHash(hash string) (PasswordHasher, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return defaultArgon2Hasher, nil
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return defaultBcryptHasher, nil
+	default:
+		return nil, fmt.Errorf("auth: malformed or unrecognized password hash")
+	}
+}
+
+// bcryptHasher is a PasswordHasher backed by golang.org/x/crypto/bcrypt,
+// the default algorithm this package always used before PasswordHasher
+// existed.
+type bcryptHasher struct {
+	cost int
+}
+
+// defaultBcryptHasher is used by hasherForHash to verify any bcrypt hash,
+// regardless of the Service's currently configured hasher; its cost field
+// is irrelevant to Verify and only matters when it's also the configured
+// hasher (see weaker).
+var defaultBcryptHasher = NewBcryptHasher(bcrypt.DefaultCost)
+
+// NewBcryptHasher returns a PasswordHasher that hashes with bcrypt at cost.
+func NewBcryptHasher(cost int) PasswordHasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("auth: failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("auth: failed to verify password: %w", err)
+	}
+	return true, nil
+}
+
+func (h *bcryptHasher) prefix() string { return "$2" }
+
+func (h *bcryptHasher) weaker(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost < h.cost
+}
+
+// argon2Params is the set of Argon2id cost parameters an argon2Hasher
+// hashes new passwords with.
+type argon2Params struct {
+	Memory      uint32 // KiB
+	Time        uint32 // iterations
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params matches the OWASP-recommended minimum for Argon2id:
+// 64 MiB of memory, 3 iterations, 2-way parallelism.
+var DefaultArgon2Params = argon2Params{
+	Memory:      64 * 1024,
+	Time:        3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// argon2Hasher is a PasswordHasher backed by golang.org/x/crypto/argon2's
+// Argon2id variant.
+type argon2Hasher struct {
+	params argon2Params
+}
+
+// defaultArgon2Hasher is used by hasherForHash to verify any Argon2id hash;
+// Verify reads the actual params back out of the hash itself, so this
+// value's params only matter when it's also the configured hasher.
+var defaultArgon2Hasher = NewArgon2Hasher(DefaultArgon2Params)
+
+// NewArgon2Hasher returns a PasswordHasher that hashes with Argon2id under
+// params.
+func NewArgon2Hasher(params argon2Params) PasswordHasher {
+	return &argon2Hasher{params: params}
+}
+
+func (h *argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("auth: failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Time, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2Hasher) Verify(password, hash string) (bool, error) {
+	params, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+func (h *argon2Hasher) prefix() string { return "$argon2id$" }
+
+func (h *argon2Hasher) weaker(hash string) bool {
+	params, _, _, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory < h.params.Memory || params.Time < h.params.Time || params.Parallelism < h.params.Parallelism
+}
+
+// decodeArgon2Hash parses a "$argon2id$v=...$m=...,t=...,p=...$salt$hash"
+// PHC string back into its params, salt, and derived key.
+func decodeArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return argon2Params{}, nil, nil, fmt.Errorf("auth: malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return argon2Params{}, nil, nil, fmt.Errorf("auth: malformed argon2id version: %w", err)
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Parallelism); err != nil {
+		return argon2Params{}, nil, nil, fmt.Errorf("auth: malformed argon2id params: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, fmt.Errorf("auth: malformed argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2Params{}, nil, nil, fmt.Errorf("auth: malformed argon2id hash payload: %w", err)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
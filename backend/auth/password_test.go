@@ -0,0 +1,124 @@
+package auth
+
+import "testing"
+
+func TestBcryptHashAndCheck(t *testing.T) {
+	service := NewService(testSecret)
+
+	hash, err := service.HashPassword("correct-password")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	ok, needsRehash, err := service.CheckPassword("correct-password", hash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if !ok {
+		t.Error("Expected correct password to verify")
+	}
+	if needsRehash {
+		t.Error("Expected a hash from the currently configured hasher to not need a rehash")
+	}
+}
+
+func TestArgon2HashAndCheck(t *testing.T) {
+	hasher := NewArgon2Hasher(DefaultArgon2Params)
+
+	hash, err := hasher.Hash("correct-password")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	service := NewService(testSecret)
+	service.SetPasswordHasher(hasher)
+
+	ok, needsRehash, err := service.CheckPassword("correct-password", hash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if !ok {
+		t.Error("Expected correct password to verify")
+	}
+	if needsRehash {
+		t.Error("Expected a hash from the currently configured hasher to not need a rehash")
+	}
+
+	ok, _, err = service.CheckPassword("wrong-password", hash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if ok {
+		t.Error("Expected wrong password to fail verification")
+	}
+}
+
+func TestCheckPasswordCrossAlgorithm(t *testing.T) {
+	bcryptHash, err := NewBcryptHasher(10).Hash("correct-password")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	service := NewService(testSecret)
+	service.SetPasswordHasher(NewArgon2Hasher(DefaultArgon2Params))
+
+	ok, needsRehash, err := service.CheckPassword("correct-password", bcryptHash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if !ok {
+		t.Error("Expected a bcrypt hash to still verify against its own algorithm")
+	}
+	if !needsRehash {
+		t.Error("Expected needsRehash when the stored hash's algorithm is weaker than the configured one")
+	}
+}
+
+func TestCheckPasswordRejectsMalformedHash(t *testing.T) {
+	service := NewService(testSecret)
+
+	if _, _, err := service.CheckPassword("password", "not-a-valid-phc-hash"); err == nil {
+		t.Error("Expected CheckPassword to reject a malformed PHC string")
+	}
+}
+
+func TestCheckPasswordSignalsRehashOnTightenedCost(t *testing.T) {
+	weakHash, err := NewBcryptHasher(4).Hash("correct-password")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	service := NewService(testSecret)
+	service.SetPasswordHasher(NewBcryptHasher(12))
+
+	ok, needsRehash, err := service.CheckPassword("correct-password", weakHash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if !ok {
+		t.Error("Expected correct password to verify even at a lower cost")
+	}
+	if !needsRehash {
+		t.Error("Expected needsRehash when policy tightens the required bcrypt cost")
+	}
+}
+
+func TestHashPasswordWithParams(t *testing.T) {
+	service := NewService(testSecret)
+
+	hash, err := service.HashPasswordWithParams("correct-password", NewBcryptHasher(4))
+	if err != nil {
+		t.Fatalf("HashPasswordWithParams failed: %v", err)
+	}
+
+	ok, needsRehash, err := service.CheckPassword("correct-password", hash)
+	if err != nil {
+		t.Fatalf("CheckPassword failed: %v", err)
+	}
+	if !ok {
+		t.Error("Expected correct password to verify")
+	}
+	if !needsRehash {
+		t.Error("Expected needsRehash since the explicit cost (4) is weaker than the default hasher (14)")
+	}
+}
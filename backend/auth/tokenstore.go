@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RefreshTokenRecord is a persisted refresh token's metadata, as returned
+// by TokenStore.Lookup.
+type RefreshTokenRecord struct {
+	UserID    string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// TokenStore persists refresh tokens and a denylist of revoked access-token
+// jtis, the state backing Service's IssueTokenPair/RefreshToken/RevokeToken
+// session subsystem. SQLTokenStore and MemTokenStore are the two provided
+// implementations.
+type TokenStore interface {
+	// Store persists a new refresh token under tokenHash (never the raw
+	// token, so a leaked database can't be replayed directly).
+	Store(ctx context.Context, tokenHash, userID string, expiresAt time.Time, userAgent, ip string) error
+	// Lookup returns the record for tokenHash, or nil if it doesn't exist.
+	Lookup(ctx context.Context, tokenHash string) (*RefreshTokenRecord, error)
+	// Rotate atomically consumes oldTokenHash (only if it's unrevoked) and
+	// stores newTokenHash in its place, returning the user it belonged to.
+	// Exactly one caller wins a race to rotate the same oldTokenHash;
+	// everyone else gets ErrRefreshTokenReused.
+	Rotate(ctx context.Context, oldTokenHash, newTokenHash string, expiresAt time.Time, userAgent, ip string) (userID string, err error)
+	// Revoke marks tokenHash revoked; a no-op if it's already revoked or
+	// doesn't exist.
+	Revoke(ctx context.Context, tokenHash string) error
+	// RevokeAllForUser marks every unrevoked refresh token for userID
+	// revoked.
+	RevokeAllForUser(ctx context.Context, userID string) error
+	// Denylist marks jti's access token as revoked until expiresAt (its
+	// own exp claim; past that it would stop validating anyway).
+	Denylist(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsDenylisted reports whether jti has been revoked.
+	IsDenylisted(ctx context.Context, jti string) (bool, error)
+}
+
+// ErrRefreshTokenReused is returned by Rotate when oldTokenHash was already
+// rotated or revoked, i.e. someone is replaying a refresh token that's
+// already been used.
+var ErrRefreshTokenReused = fmt.Errorf("refresh token already used or revoked")
+
+// SQLTokenStore persists refresh tokens in the refresh_tokens table and
+// denylisted jtis in revoked_access_tokens, via the same sqlDB seam the
+// rest of auth uses to avoid depending on the concrete database driver.
+type SQLTokenStore struct {
+	db sqlDB
+}
+
+// NewSQLTokenStore returns a TokenStore backed by database.
+func NewSQLTokenStore(database sqlDB) *SQLTokenStore {
+	return &SQLTokenStore{db: database}
+}
+
+func (s *SQLTokenStore) Store(ctx context.Context, tokenHash, userID string, expiresAt time.Time, userAgent, ip string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (user_id, token_hash, expires_at, user_agent, ip) VALUES ($1, $2, $3, $4, $5)`,
+		userID, tokenHash, expiresAt, userAgent, ip,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLTokenStore) Lookup(ctx context.Context, tokenHash string) (*RefreshTokenRecord, error) {
+	var record RefreshTokenRecord
+	var revokedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = $1`,
+		tokenHash,
+	).Scan(&record.UserID, &record.ExpiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up refresh token: %w", err)
+	}
+	if revokedAt.Valid {
+		record.RevokedAt = &revokedAt.Time
+	}
+	return &record, nil
+}
+
+func (s *SQLTokenStore) Rotate(ctx context.Context, oldTokenHash, newTokenHash string, expiresAt time.Time, userAgent, ip string) (string, error) {
+	// The UPDATE ... WHERE revoked_at IS NULL only ever affects a row once;
+	// under concurrent rotation of the same token, the database's row lock
+	// lets exactly one caller get a row back from RETURNING, and everyone
+	// else gets sql.ErrNoRows.
+	var userID string
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW()
+		 WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		 RETURNING user_id`,
+		oldTokenHash,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", ErrRefreshTokenReused
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	if err := s.Store(ctx, newTokenHash, userID, expiresAt, userAgent, ip); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func (s *SQLTokenStore) Revoke(ctx context.Context, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL`,
+		tokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLTokenStore) RevokeAllForUser(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLTokenStore) Denylist(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO revoked_access_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`,
+		jti, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to denylist access token: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLTokenStore) IsDenylisted(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM revoked_access_tokens WHERE jti = $1 AND expires_at > NOW())`,
+		jti,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check access token denylist: %w", err)
+	}
+	return exists, nil
+}
+
+// MemTokenStore is an in-memory TokenStore, for tests and single-process
+// deployments that don't need refresh tokens to survive a restart.
+type MemTokenStore struct {
+	mu       sync.Mutex
+	tokens   map[string]*memRefreshToken
+	denylist map[string]time.Time // jti -> expiresAt
+}
+
+type memRefreshToken struct {
+	userID    string
+	expiresAt time.Time
+	revokedAt *time.Time
+}
+
+// NewMemTokenStore returns an empty in-memory TokenStore.
+func NewMemTokenStore() *MemTokenStore {
+	return &MemTokenStore{
+		tokens:   make(map[string]*memRefreshToken),
+		denylist: make(map[string]time.Time),
+	}
+}
+
+func (s *MemTokenStore) Store(ctx context.Context, tokenHash, userID string, expiresAt time.Time, userAgent, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[tokenHash] = &memRefreshToken{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemTokenStore) Lookup(ctx context.Context, tokenHash string) (*RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return nil, nil
+	}
+	return &RefreshTokenRecord{UserID: token.userID, ExpiresAt: token.expiresAt, RevokedAt: token.revokedAt}, nil
+}
+
+func (s *MemTokenStore) Rotate(ctx context.Context, oldTokenHash, newTokenHash string, expiresAt time.Time, userAgent, ip string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[oldTokenHash]
+	if !ok || token.revokedAt != nil || time.Now().After(token.expiresAt) {
+		return "", ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	token.revokedAt = &now
+	s.tokens[newTokenHash] = &memRefreshToken{userID: token.userID, expiresAt: expiresAt}
+	return token.userID, nil
+}
+
+func (s *MemTokenStore) Revoke(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token, ok := s.tokens[tokenHash]; ok && token.revokedAt == nil {
+		now := time.Now()
+		token.revokedAt = &now
+	}
+	return nil
+}
+
+func (s *MemTokenStore) RevokeAllForUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, token := range s.tokens {
+		if token.userID == userID && token.revokedAt == nil {
+			token.revokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *MemTokenStore) Denylist(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denylist[jti] = expiresAt
+	return nil
+}
+
+func (s *MemTokenStore) IsDenylisted(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt, ok := s.denylist[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// parseUnverifiedJTI extracts the jti claim from token without verifying its
+// signature, just enough for a test to simulate an operator denylisting a
+// token it's already seen be issued.
+func parseUnverifiedJTI(token string) (string, error) {
+	parser := jwt.NewParser()
+	claims := jwt.MapClaims{}
+	if _, _, err := parser.ParseUnverified(token, claims); err != nil {
+		return "", err
+	}
+	jti, _ := claims["jti"].(string)
+	return jti, nil
+}
+
+func TestMemTokenStoreRotateRejectsReuse(t *testing.T) {
+	store := NewMemTokenStore()
+	ctx := context.Background()
+
+	if err := store.Store(ctx, "old-hash", "user-1", time.Now().Add(time.Hour), "agent", "127.0.0.1"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	userID, err := store.Rotate(ctx, "old-hash", "new-hash", time.Now().Add(time.Hour), "agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if userID != "user-1" {
+		t.Errorf("Expected user-1, got %s", userID)
+	}
+
+	if _, err := store.Rotate(ctx, "old-hash", "newer-hash", time.Now().Add(time.Hour), "agent", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("Expected ErrRefreshTokenReused for a replayed token, got %v", err)
+	}
+
+	if _, err := store.Lookup(ctx, "new-hash"); err != nil {
+		t.Errorf("Expected the rotated-to token to be looked up without error, got %v", err)
+	}
+}
+
+func TestMemTokenStoreRotateRejectsExpired(t *testing.T) {
+	store := NewMemTokenStore()
+	ctx := context.Background()
+
+	if err := store.Store(ctx, "old-hash", "user-1", time.Now().Add(-time.Minute), "agent", "127.0.0.1"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	if _, err := store.Rotate(ctx, "old-hash", "new-hash", time.Now().Add(time.Hour), "agent", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("Expected ErrRefreshTokenReused for an expired token, got %v", err)
+	}
+}
+
+func TestMemTokenStoreConcurrentRotateHasExactlyOneWinner(t *testing.T) {
+	store := NewMemTokenStore()
+	ctx := context.Background()
+
+	if err := store.Store(ctx, "shared-hash", "user-1", time.Now().Add(time.Hour), "agent", "127.0.0.1"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	const attempts = 20
+	var wins int32
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		go func(i int) {
+			defer wg.Done()
+			newHash := string(rune('a' + i))
+			if _, err := store.Rotate(ctx, "shared-hash", newHash, time.Now().Add(time.Hour), "agent", "127.0.0.1"); err == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if wins != 1 {
+		t.Errorf("Expected exactly one winning rotation out of %d concurrent attempts, got %d", attempts, wins)
+	}
+}
+
+func TestMemTokenStoreRevokeAndDenylist(t *testing.T) {
+	store := NewMemTokenStore()
+	ctx := context.Background()
+
+	if err := store.Store(ctx, "hash-1", "user-1", time.Now().Add(time.Hour), "agent", "127.0.0.1"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := store.Revoke(ctx, "hash-1"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+
+	record, err := store.Lookup(ctx, "hash-1")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if record.RevokedAt == nil {
+		t.Error("Expected RevokedAt to be set after Revoke")
+	}
+
+	if err := store.Denylist(ctx, "some-jti", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Denylist failed: %v", err)
+	}
+	denylisted, err := store.IsDenylisted(ctx, "some-jti")
+	if err != nil {
+		t.Fatalf("IsDenylisted failed: %v", err)
+	}
+	if !denylisted {
+		t.Error("Expected jti to be denylisted")
+	}
+	if denylisted2, _ := store.IsDenylisted(ctx, "other-jti"); denylisted2 {
+		t.Error("Expected an un-denylisted jti to report false")
+	}
+}
+
+func TestValidateTokenRejectsDenylistedJTI(t *testing.T) {
+	store := NewMemTokenStore()
+	service := NewServiceWithTokenStore(newStaticHMACProvider(testSecret), nil, store)
+
+	token, err := service.GenerateToken("user-1", "user@example.com", "User")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	user, err := service.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Expected a fresh token to validate, got %v", err)
+	}
+	if user.ID != "user-1" {
+		t.Errorf("Expected user-1, got %s", user.ID)
+	}
+
+	jti, err := parseUnverifiedJTI(token)
+	if err != nil {
+		t.Fatalf("failed to read jti from token: %v", err)
+	}
+	if jti == "" {
+		t.Fatal("Expected the token to carry a jti claim")
+	}
+
+	if err := store.Denylist(context.Background(), jti, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Denylist failed: %v", err)
+	}
+
+	if _, err := service.ValidateToken(context.Background(), token); err == nil {
+		t.Error("Expected a denylisted token to fail validation")
+	}
+}
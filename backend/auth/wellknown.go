@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// jwk is a single entry in a JWKS document (RFC 7517), covering the RSA and
+// OKP (Ed25519) key types this package issues.
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS builds a JSON Web Key Set document containing every non-retired
+// public key from keyProvider. HS256 keys are symmetric and therefore never
+// included; a provider backed entirely by HS256 yields an empty key set.
+func JWKS(keyProvider KeyProvider) (map[string]interface{}, error) {
+	keys, err := keyProvider.VerificationKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load verification keys: %w", err)
+	}
+
+	jwks := make([]jwk, 0, len(keys))
+	for _, key := range keys {
+		entry, ok, err := toJWK(key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			jwks = append(jwks, entry)
+		}
+	}
+
+	return map[string]interface{}{"keys": jwks}, nil
+}
+
+func toJWK(key *SigningKey) (jwk, bool, error) {
+	switch pub := key.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		return jwk{
+			Kty: "RSA",
+			Use: "sig",
+			Kid: key.Kid,
+			Alg: key.Algorithm,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(bigEndianUint(pub.E)),
+		}, true, nil
+	case ed25519.PublicKey:
+		return jwk{
+			Kty: "OKP",
+			Use: "sig",
+			Kid: key.Kid,
+			Alg: key.Algorithm,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, true, nil
+	case []byte:
+		// Symmetric HS256 key: never published.
+		return jwk{}, false, nil
+	default:
+		return jwk{}, false, fmt.Errorf("auth: unsupported public key type %T for kid %q", pub, key.Kid)
+	}
+}
+
+// bigEndianUint encodes a small positive int (an RSA public exponent, e.g.
+// 65537) as minimal big-endian bytes for the JWK "e" member.
+func bigEndianUint(n int) []byte {
+	if n == 0 {
+		return []byte{0}
+	}
+	var b []byte
+	for n > 0 {
+		b = append([]byte{byte(n & 0xff)}, b...)
+		n >>= 8
+	}
+	return b
+}
+
+// JWKSHandler serves GET /.well-known/jwks.json.
+func JWKSHandler(keyProvider KeyProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, err := JWKS(keyProvider)
+		if err != nil {
+			http.Error(w, "failed to build JWKS", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(doc)
+	}
+}
+
+// OIDCConfigHandler serves GET /.well-known/openid-configuration with the
+// minimum fields clients need to discover the JWKS and token endpoints.
+func OIDCConfigHandler(issuer string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc := map[string]interface{}{
+			"issuer":         issuer,
+			"jwks_uri":       issuer + "/.well-known/jwks.json",
+			"token_endpoint": issuer + "/query",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(doc)
+	}
+}
@@ -0,0 +1,168 @@
+// Package changefeed is the pub/sub bus behind the FileChanged/
+// DirectoryChanged GraphQL subscriptions: every publish is first persisted
+// to the file_events table (so Since can replay whatever a reconnecting
+// subscriber missed), then fanned out to whichever of that user's
+// subscriptions are live right now. This is the foundation for a two-way
+// sync client analogous to Syncthing's index-update flow.
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aleksandr/lifetrack/backend/db"
+)
+
+// Operation identifies what kind of change an Event describes.
+type Operation string
+
+const (
+	OperationCreate Operation = "CREATE"
+	OperationUpdate Operation = "UPDATE"
+	OperationDelete Operation = "DELETE"
+	OperationMove   Operation = "MOVE"
+)
+
+// EntityType identifies what an Event's Payload deserializes into.
+type EntityType string
+
+const (
+	EntityFile      EntityType = "file"
+	EntityDirectory EntityType = "directory"
+)
+
+// Event is one row of the change feed: a single create/update/delete/move
+// of a file or directory, in publish order.
+type Event struct {
+	Seq        int64
+	UserID     string
+	EntityType EntityType
+	Operation  Operation
+	EntityID   string // file ID, empty for directory events
+	Path       string // the file's directory, or the directory's own path
+	Payload    json.RawMessage
+	CreatedAt  time.Time
+}
+
+// Bus persists events and fans them out to live subscribers. The zero
+// value is not usable; construct one with NewBus.
+type Bus struct {
+	db *db.DB
+
+	mu          sync.Mutex
+	subscribers map[string][]chan *Event
+}
+
+// NewBus constructs a Bus backed by database.
+func NewBus(database *db.DB) *Bus {
+	return &Bus{db: database, subscribers: make(map[string][]chan *Event)}
+}
+
+// Publish persists an event for userID and delivers it to that user's live
+// subscribers. entity is marshaled to JSON as the event's Payload, so
+// subscribers can deserialize it back into the model.File/model.Directory
+// it was published with.
+func (b *Bus) Publish(ctx context.Context, userID string, entityType EntityType, operation Operation, entityID, path string, entity interface{}) (*Event, error) {
+	payload, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal change-feed event: %w", err)
+	}
+
+	event := &Event{
+		UserID:     userID,
+		EntityType: entityType,
+		Operation:  operation,
+		EntityID:   entityID,
+		Path:       path,
+		Payload:    payload,
+	}
+
+	err = b.db.QueryRowContext(ctx, `
+		INSERT INTO file_events (user_id, entity_type, operation, entity_id, path, payload)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING seq, created_at`,
+		userID, entityType, operation, entityID, path, payload,
+	).Scan(&event.Seq, &event.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record change-feed event: %w", err)
+	}
+
+	b.deliver(event)
+	return event, nil
+}
+
+// Subscribe registers a new live subscription for userID and returns a
+// channel of events along with a function to unsubscribe and release it.
+// The channel is buffered; once a subscriber's buffer is full, further
+// events are skipped for it rather than blocking Publish (the subscription
+// itself stays open), since it can always use Since to replay what it
+// missed.
+func (b *Bus) Subscribe(userID string) (<-chan *Event, func()) {
+	ch := make(chan *Event, 64)
+
+	b.mu.Lock()
+	b.subscribers[userID] = append(b.subscribers[userID], ch)
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := b.subscribers[userID]
+		for i, existing := range subs {
+			if existing == ch {
+				b.subscribers[userID] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+func (b *Bus) deliver(event *Event) {
+	b.mu.Lock()
+	subs := append([]chan *Event(nil), b.subscribers[event.UserID]...)
+	b.mu.Unlock()
+
+	for _, ch := range subs {
+		select {
+		case ch <- event:
+		default:
+			// Subscriber is too far behind to keep up live; it can still
+			// call Since to replay what it missed once it catches up.
+		}
+	}
+}
+
+// Since returns every event for userID with seq > sinceSeq, ordered by seq,
+// so a reconnecting subscriber can catch up without re-listing.
+func (b *Bus) Since(ctx context.Context, userID string, sinceSeq int64) ([]*Event, error) {
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT seq, user_id, entity_type, operation, COALESCE(entity_id, ''), path, payload, created_at
+		FROM file_events WHERE user_id = $1 AND seq > $2 ORDER BY seq`,
+		userID, sinceSeq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missed events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(
+			&event.Seq, &event.UserID, &event.EntityType, &event.Operation,
+			&event.EntityID, &event.Path, &event.Payload, &event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan missed event: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
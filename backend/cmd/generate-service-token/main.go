@@ -2,24 +2,30 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aleksandr/lifetrack/backend/auth"
 	"github.com/aleksandr/lifetrack/backend/db"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 func main() {
+	scopesFlag := flag.String("scopes", "", "comma-separated scopes to grant the service token, e.g. entries:write,files:read")
+	flag.Parse()
+
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		fmt.Fprintln(os.Stderr, "Error: JWT_SECRET environment variable is required")
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Usage:")
-		fmt.Fprintln(os.Stderr, "  JWT_SECRET=your-secret DATABASE_URL=your-db-url ./generate-service-token [service-name]")
+		fmt.Fprintln(os.Stderr, "  JWT_SECRET=your-secret DATABASE_URL=your-db-url ./generate-service-token [--scopes entries:write,files:read] [service-name]")
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Example:")
-		fmt.Fprintln(os.Stderr, "  JWT_SECRET=my-secret DATABASE_URL=postgres://... ./generate-service-token telegram-bot")
+		fmt.Fprintln(os.Stderr, "  JWT_SECRET=my-secret DATABASE_URL=postgres://... ./generate-service-token --scopes entries:write telegram-bot")
 		os.Exit(1)
 	}
 
@@ -30,8 +36,13 @@ func main() {
 	}
 
 	serviceName := "telegram-bot"
-	if len(os.Args) > 1 {
-		serviceName = os.Args[1]
+	if flag.NArg() > 0 {
+		serviceName = flag.Arg(0)
+	}
+
+	var scopes []string
+	if *scopesFlag != "" {
+		scopes = strings.Split(*scopesFlag, ",")
 	}
 
 	// Connect to database
@@ -55,25 +66,30 @@ func main() {
 		// Create service account
 		serviceID = uuid.New().String()
 		_, err = database.Exec(`
-			INSERT INTO users (id, email, name, is_service, is_active, password_hash)
-			VALUES ($1, $2, $3, TRUE, TRUE, NULL)
-		`, serviceID, email, serviceName)
+			INSERT INTO users (id, email, name, is_service, is_active, password_hash, service_scopes)
+			VALUES ($1, $2, $3, TRUE, TRUE, NULL, $4)
+		`, serviceID, email, serviceName, pq.Array(scopes))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to create service account: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "✓ Created service account: %s (ID: %s)\n", serviceName, serviceID)
+		fmt.Fprintf(os.Stderr, "✓ Created service account: %s (ID: %s, scopes: %v)\n", serviceName, serviceID, scopes)
 	} else if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to query database: %v\n", err)
 		os.Exit(1)
 	} else {
 		serviceID = existingID
-		fmt.Fprintf(os.Stderr, "✓ Service account already exists: %s (ID: %s)\n", serviceName, serviceID)
+		if _, err := database.Exec(`UPDATE users SET service_scopes = $1 WHERE id = $2`, pq.Array(scopes), serviceID); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to update service scopes: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Service account already exists: %s (ID: %s, scopes: %v)\n", serviceName, serviceID, scopes)
 	}
 
-	// Generate token with is_service flag
+	// Generate token carrying the configured scopes (is_service is kept for
+	// logging/audit only; see auth.RequireScope for authorization checks).
 	authService := auth.NewService(jwtSecret)
-	token, err := authService.GenerateTokenWithFlags(serviceID, email, serviceName, true)
+	token, err := authService.GenerateTokenWithScopes(serviceID, email, serviceName, true, scopes)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to generate token: %v\n", err)
 		os.Exit(1)
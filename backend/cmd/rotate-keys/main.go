@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aleksandr/lifetrack/backend/auth"
+	"github.com/aleksandr/lifetrack/backend/db"
+)
+
+func main() {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		fmt.Fprintln(os.Stderr, "Error: DATABASE_URL environment variable is required")
+		os.Exit(1)
+	}
+
+	algorithm := os.Getenv("JWT_SIGNING_ALGORITHM")
+	if algorithm == "" {
+		algorithm = "RS256"
+	}
+
+	grace := 24 * time.Hour
+	if raw := os.Getenv("ROTATE_KEYS_GRACE"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid ROTATE_KEYS_GRACE %q: %v\n", raw, err)
+			os.Exit(1)
+		}
+		grace = parsed
+	}
+
+	masterKeyHex := os.Getenv("SIGNING_KEY_ENCRYPTION_KEY")
+	if masterKeyHex == "" {
+		fmt.Fprintln(os.Stderr, "Error: SIGNING_KEY_ENCRYPTION_KEY environment variable is required")
+		os.Exit(1)
+	}
+	masterKey, err := hex.DecodeString(masterKeyHex)
+	if err != nil || len(masterKey) != 32 {
+		fmt.Fprintln(os.Stderr, "Error: SIGNING_KEY_ENCRYPTION_KEY must be a hex-encoded 32-byte key")
+		os.Exit(1)
+	}
+
+	database, err := db.NewDB(databaseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	keyProvider, err := auth.NewDBKeyProvider(ctx, database, algorithm, masterKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load signing keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	newKey, err := keyProvider.RotateKeys(ctx, grace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to rotate keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Rotated signing keys: new active kid=%s (%s)\n", newKey.Kid, newKey.Algorithm)
+	fmt.Fprintf(os.Stderr, "  Previous key remains valid for verification for %s\n", grace)
+}
@@ -2,9 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/textproto"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -12,14 +25,179 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/aleksandr/lifetrack/backend/auth"
+	"github.com/aleksandr/lifetrack/backend/changefeed"
 	"github.com/aleksandr/lifetrack/backend/db"
+	"github.com/aleksandr/lifetrack/backend/files"
+	"github.com/aleksandr/lifetrack/backend/filerpc"
+	"github.com/aleksandr/lifetrack/backend/filerpc/pb"
 	"github.com/aleksandr/lifetrack/backend/graph"
+	"github.com/aleksandr/lifetrack/backend/graph/cache"
+	"github.com/aleksandr/lifetrack/backend/streamurl"
+	"github.com/aleksandr/lifetrack/backend/telegram"
+	"github.com/aleksandr/lifetrack/backend/trash"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"google.golang.org/grpc"
 )
 
 const defaultPort = "8080"
 
+// fileStorageRoot resolves the on-disk root for the content-addressed
+// object store, matching the default the download/upload handlers have
+// always assumed.
+func fileStorageRoot() string {
+	root := os.Getenv("FILE_STORAGE_PATH")
+	if root == "" {
+		root = "../data/files"
+	}
+	return root
+}
+
+// buildAuthService constructs the auth.Service per JWT_SIGNING_ALGORITHM:
+// "HS256" (default) signs with JWT_SECRET, while "RS256"/"EdDSA" sign with a
+// key pair managed by auth.DBKeyProvider.
+func buildAuthService(database *db.DB) (*auth.Service, error) {
+	algorithm := os.Getenv("JWT_SIGNING_ALGORITHM")
+	if algorithm == "" || algorithm == "HS256" {
+		jwtSecret := os.Getenv("JWT_SECRET")
+		if jwtSecret == "" {
+			log.Fatal("JWT_SECRET environment variable is required when JWT_SIGNING_ALGORITHM=HS256")
+		}
+		return auth.NewServiceWithDB(jwtSecret, database), nil
+	}
+
+	masterKey, err := signingKeyMasterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	keyProvider, err := auth.NewDBKeyProvider(context.Background(), database, algorithm, masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	return auth.NewServiceWithKeyProvider(keyProvider, database), nil
+}
+
+// signingKeyMasterKey reads the 32-byte AES-256 key (hex-encoded in
+// SIGNING_KEY_ENCRYPTION_KEY) that encrypts signing_keys.private_pem_encrypted
+// at rest. It must be kept separately from DATABASE_URL so a leak of one
+// doesn't also expose the other.
+func signingKeyMasterKey() ([]byte, error) {
+	raw := os.Getenv("SIGNING_KEY_ENCRYPTION_KEY")
+	if raw == "" {
+		log.Fatal("SIGNING_KEY_ENCRYPTION_KEY environment variable is required when JWT_SIGNING_ALGORITHM=RS256/EdDSA")
+	}
+	key, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("SIGNING_KEY_ENCRYPTION_KEY must be hex-encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("SIGNING_KEY_ENCRYPTION_KEY must decode to 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// defaultTrashTTL and defaultTrashSweepInterval configure the background
+// sweeper (see the trash package) when TRASH_RETENTION/TRASH_SWEEP_INTERVAL
+// aren't set.
+const (
+	defaultTrashTTL           = 30 * 24 * time.Hour
+	defaultTrashSweepInterval = time.Hour
+)
+
+// trashConfig builds the trash.Config from TRASH_RETENTION and
+// TRASH_SWEEP_INTERVAL (both Go duration strings, e.g. "720h" or "1h").
+func trashConfig() trash.Config {
+	config := trash.Config{TTL: defaultTrashTTL, Interval: defaultTrashSweepInterval}
+
+	if raw := os.Getenv("TRASH_RETENTION"); raw != "" {
+		if parsed, err := time.ParseDuration(raw); err == nil {
+			config.TTL = parsed
+		} else {
+			log.Printf("Invalid TRASH_RETENTION %q, using default %s", raw, defaultTrashTTL)
+		}
+	}
+	if raw := os.Getenv("TRASH_SWEEP_INTERVAL"); raw != "" {
+		if parsed, err := time.ParseDuration(raw); err == nil {
+			config.Interval = parsed
+		} else {
+			log.Printf("Invalid TRASH_SWEEP_INTERVAL %q, using default %s", raw, defaultTrashSweepInterval)
+		}
+	}
+
+	return config
+}
+
+// defaultAPQTTL is how long a persisted query stays cached when APQ_TTL
+// isn't set.
+const defaultAPQTTL = time.Hour
+
+// newAPQCache builds the cache.Cache backing AutomaticPersistedQuery based
+// on APQ_CACHE (memory|redis), REDIS_URL, APQ_TTL, and APQ_MAX_ENTRIES. It
+// defaults to the bounded in-memory LRU so a missing REDIS_URL doesn't
+// silently fall back to an unbounded cache.
+func newAPQCache() cache.Cache {
+	ttl := defaultAPQTTL
+	if raw := os.Getenv("APQ_TTL"); raw != "" {
+		if parsed, err := time.ParseDuration(raw); err == nil {
+			ttl = parsed
+		} else {
+			log.Printf("Invalid APQ_TTL %q, using default %s", raw, defaultAPQTTL)
+		}
+	}
+
+	maxEntries := 1000
+	if raw := os.Getenv("APQ_MAX_ENTRIES"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil {
+			maxEntries = parsed
+		} else {
+			log.Printf("Invalid APQ_MAX_ENTRIES %q, using default %d", raw, maxEntries)
+		}
+	}
+
+	switch os.Getenv("APQ_CACHE") {
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			log.Fatal("APQ_CACHE=redis requires REDIS_URL to be set")
+		}
+		return cache.NewRedis(cache.RedisAddrFromURL(redisURL), ttl)
+	default:
+		lruCache, err := cache.NewLRU(cache.LRUConfig{MaxEntries: maxEntries, TTL: ttl})
+		if err != nil {
+			log.Fatalf("Failed to create APQ cache: %v", err)
+		}
+		return lruCache
+	}
+}
+
+// startFileRPCServer starts the filerpc.FileService gRPC server on port in
+// its own goroutine. Every call is authenticated the same way the GraphQL
+// API is: a bearer token, here carried in the "authorization" gRPC metadata
+// entry instead of an HTTP header.
+func startFileRPCServer(port string, database *db.DB, fileStorage *files.Storage, authService *auth.Service) error {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", port, err)
+	}
+
+	interceptor := &filerpc.AuthInterceptor{Auth: authService}
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()),
+	)
+	pb.RegisterFileServiceServer(grpcServer, filerpc.NewFileService(database, fileStorage))
+
+	go func() {
+		log.Printf("gRPC file service ready at :%s/", port)
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
 func main() {
 	// Load .env file if it exists (optional in Docker)
 	err := godotenv.Load()
@@ -39,18 +217,69 @@ func main() {
 	}
 	defer database.Close()
 
-	// Initialize auth service
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
+	// Initialize auth service. JWT_SIGNING_ALGORITHM selects HS256 (the
+	// default, a single shared secret) or RS256/EdDSA, where keys are
+	// generated on first boot and persisted to the signing_keys table so
+	// other services can verify tokens via the JWKS endpoint below.
+	authService, err := buildAuthService(database)
+	if err != nil {
+		log.Fatalf("Failed to initialize auth service: %v", err)
+	}
+
+	// Content-addressable object store backing the upload/download
+	// endpoints below; see files.Storage for the on-disk layout.
+	fileStorage, err := files.NewStorage(files.Config{StorageRoot: fileStorageRoot()})
+	if err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
+	}
+
+	// STREAM_URL_SECRET signs the short-lived URLs StreamURL hands out for
+	// GET /files/stream/{id}; it's independent of JWT_SECRET since these
+	// URLs authorize a single file rather than a user session.
+	streamURLSecret := os.Getenv("STREAM_URL_SECRET")
+	if streamURLSecret == "" {
+		log.Fatal("STREAM_URL_SECRET environment variable is required")
+	}
+	streamURLSigner := streamurl.NewSigner(streamURLSecret)
+
+	// Telegram-backed file streaming is optional: without TELEGRAM_BOT_TOKEN,
+	// files are only ever served from the local content-addressed store.
+	var telegramClient *telegram.Client
+	if token := os.Getenv("TELEGRAM_BOT_TOKEN"); token != "" {
+		telegramClient = telegram.NewClient(token)
+	}
+
+	// Background sweeper: hard-deletes files/directories that DeleteFile,
+	// DeleteDirectory, or Trash moved to the trash once they've sat there
+	// longer than TRASH_RETENTION, releasing storage for anything trashed
+	// with removeFromStorage.
+	trashSweeper := trash.NewSweeper(database, fileStorage, trashConfig())
+	go trashSweeper.Run(context.Background())
+
+	// Change feed backing the FileChanged/DirectoryChanged subscriptions:
+	// the CreateFile, UpdateFile, DeleteFile, MoveFile, CreateDirectory and
+	// DeleteDirectory mutations publish into it, and it persists each
+	// event so reconnecting subscribers can catch up via since_seq.
+	changeBus := changefeed.NewBus(database)
+
+	// gRPC sibling API: the same file/directory operations as the GraphQL
+	// resolvers, for non-browser clients (mobile apps, sync daemons) that
+	// would rather speak protobuf. Disabled unless GRPC_PORT is set.
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		if err := startFileRPCServer(grpcPort, database, fileStorage, authService); err != nil {
+			log.Fatalf("Failed to start gRPC server: %v", err)
+		}
 	}
-	authService := auth.NewService(jwtSecret)
 
 	// Initialize GraphQL server
 	srv := handler.New(graph.NewExecutableSchema(graph.Config{
 		Resolvers: &graph.Resolver{
-			DB:   database,
-			Auth: authService,
+			DB:              database,
+			Auth:            authService,
+			Storage:         fileStorage,
+			StreamURLSigner: streamURLSigner,
+			Telegram:        telegramClient,
+			Changes:         changeBus,
 		},
 	}))
 
@@ -66,13 +295,22 @@ func main() {
 	// Use custom introspection with isDeprecated support for __InputValue
 	srv.Use(extension.Introspection{})
 	srv.Use(extension.AutomaticPersistedQuery{
-		Cache: &graph.InMemoryCache{},
+		Cache: newAPQCache(),
 	})
 
 	// Setup routes
 	http.Handle("/", playground.Handler("LifeTrack GraphQL Playground", "/query"))
 	http.Handle("/query", auth.Middleware(authService)(srv))
 
+	// JWKS/OIDC discovery so external services can verify tokens without
+	// holding the signing secret (a no-op key set when signing with HS256).
+	issuerURL := os.Getenv("ISSUER_URL")
+	if issuerURL == "" {
+		issuerURL = "http://localhost:" + port
+	}
+	http.Handle("/.well-known/jwks.json", auth.JWKSHandler(authService.KeyProvider()))
+	http.Handle("/.well-known/openid-configuration", auth.OIDCConfigHandler(issuerURL))
+
 	// Health check endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		if err := database.Ping(context.Background()); err != nil {
@@ -83,7 +321,10 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
-	// File download endpoint
+	// File download endpoint: serves the content-addressed object
+	// referenced by the files row, not a path on disk, so the same blob
+	// backing multiple files rows is served identically regardless of
+	// which logical file was requested.
 	http.Handle("/files/download/", auth.Middleware(authService)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract file ID from URL path
 		fileID := r.URL.Path[len("/files/download/"):]
@@ -100,36 +341,33 @@ func main() {
 		}
 
 		// Query file from database
-		var filename, storagePath, mimeType string
+		var filename, mimeType, sha256Hash string
 		err = database.QueryRowContext(r.Context(),
-			"SELECT filename, storage_path, mime_type FROM files WHERE id = $1 AND user_id = $2",
+			"SELECT filename, mime_type, sha256 FROM files WHERE id = $1 AND user_id = $2",
 			fileID, user.ID,
-		).Scan(&filename, &storagePath, &mimeType)
+		).Scan(&filename, &mimeType, &sha256Hash)
 		if err != nil {
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
 
-		// Get storage root from environment or use default
-		storageRoot := os.Getenv("FILE_STORAGE_PATH")
-		if storageRoot == "" {
-			storageRoot = "../data/files"
+		etag := `"sha256:` + sha256Hash + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		if match := r.Header.Get("If-None-Match"); match == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
 
-		// Construct full file path
-		filePath := storageRoot + "/" + storagePath
-
-		// Open file
-		file, err := os.Open(filePath)
+		object, err := fileStorage.OpenObject(sha256Hash)
 		if err != nil {
-			log.Printf("Error opening file %s: %v", filePath, err)
+			log.Printf("Error opening object %s: %v", sha256Hash, err)
 			http.Error(w, "File not found on disk", http.StatusNotFound)
 			return
 		}
-		defer file.Close()
+		defer object.Close()
 
-		// Get file info for size
-		fileInfo, err := file.Stat()
+		fileInfo, err := object.(*os.File).Stat()
 		if err != nil {
 			http.Error(w, "Error reading file", http.StatusInternalServerError)
 			return
@@ -140,10 +378,777 @@ func main() {
 		w.Header().Set("Content-Type", mimeType)
 
 		// Stream file to response (automatically sets Content-Length)
-		http.ServeContent(w, r, filename, fileInfo.ModTime(), file)
+		http.ServeContent(w, r, filename, fileInfo.ModTime(), object.(*os.File))
+	})))
+
+	// Chunked resumable upload API, modeled on git-lfs: init allocates an
+	// uploadID, PATCH appends chunks by byte offset, and complete verifies
+	// the client-declared sha256 before recording the file in the DB.
+	http.Handle("/files/upload/init", auth.Middleware(authService)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := auth.GetUserFromContext(r.Context()); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		uploadID, err := fileStorage.InitUpload()
+		if err != nil {
+			log.Printf("Error initializing upload: %v", err)
+			http.Error(w, "Failed to initialize upload", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"uploadId": uploadID,
+			"offset":   0,
+		})
+	})))
+
+	http.Handle("/files/upload/", auth.Middleware(authService)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := auth.GetUserFromContext(r.Context()); err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rest := strings.TrimPrefix(r.URL.Path, "/files/upload/")
+		uploadID, action, hasAction := strings.Cut(rest, "/")
+		if uploadID == "" {
+			http.Error(w, "Upload ID required", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case !hasAction && r.Method == http.MethodPatch:
+			handleUploadChunk(w, r, fileStorage, uploadID)
+		case hasAction && action == "complete" && r.Method == http.MethodPost:
+			handleUploadComplete(w, r, database, fileStorage, uploadID)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	})))
+
+	// Signed streaming endpoint: no Authorization header required, since
+	// possession of a valid signature is itself the authorization. Supports
+	// single- and multi-range requests, serving from Telegram when the file
+	// has a telegram_file_id and falling back to the local object store
+	// otherwise.
+	http.Handle("/files/stream/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleStreamFile(w, r, database, fileStorage, telegramClient, streamURLSigner)
+	}))
+
+	// Resumable upload driven by a manifest of pre-hashed chunks, so chunks
+	// can be uploaded out of order or in parallel; reassembles into a
+	// files row (and, when TELEGRAM_BOT_TOKEN is set, a Telegram document)
+	// on completion.
+	http.Handle("/files/stream-upload/init", auth.Middleware(authService)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleStreamUploadInit(w, r, database, fileStorage)
+	})))
+	http.Handle("/files/stream-upload/", auth.Middleware(authService)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/files/stream-upload/")
+		uploadID, action, hasAction := strings.Cut(rest, "/")
+		if uploadID == "" {
+			http.Error(w, "Upload ID required", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case hasAction && strings.HasPrefix(action, "chunks/") && r.Method == http.MethodPut:
+			chunkSHA256 := strings.TrimPrefix(action, "chunks/")
+			handleStreamUploadChunk(w, r, database, fileStorage, uploadID, chunkSHA256)
+		case hasAction && action == "complete" && r.Method == http.MethodPost:
+			handleStreamUploadComplete(w, r, database, fileStorage, telegramClient, uploadID)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
 	})))
 
 	log.Printf("ðŸš€ Server ready at http://localhost:%s/", port)
 	log.Printf("ðŸŽ® GraphQL Playground at http://localhost:%s/", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
+
+func writeJSON(w http.ResponseWriter, status int, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleUploadChunk writes a Content-Range chunk into an in-progress
+// upload. Content-Range must be of the form "bytes {start}-{end}/{total}";
+// start must equal the upload's current offset so chunks can't be applied
+// out of order or skip bytes.
+func handleUploadChunk(w http.ResponseWriter, r *http.Request, fileStorage *files.Storage, uploadID string) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start, _, _, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, "Invalid or missing Content-Range: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	currentOffset, err := fileStorage.UploadOffset(uploadID)
+	if err != nil {
+		http.Error(w, "Unknown upload ID", http.StatusNotFound)
+		return
+	}
+	if start != currentOffset {
+		http.Error(w, fmt.Sprintf("Expected chunk at offset %d, got %d", currentOffset, start), http.StatusConflict)
+		return
+	}
+
+	newOffset, err := fileStorage.WriteChunk(uploadID, start, r.Body)
+	if err != nil {
+		log.Printf("Error writing chunk for upload %s: %v", uploadID, err)
+		http.Error(w, "Failed to write chunk", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"uploadId": uploadID,
+		"offset":   newOffset,
+	})
+}
+
+// uploadCompleteRequest is the body POSTed to /files/upload/{id}/complete.
+type uploadCompleteRequest struct {
+	SHA256    string `json:"sha256"`
+	Filename  string `json:"filename"`
+	MimeType  string `json:"mimeType"`
+	Directory string `json:"directory"`
+}
+
+// handleUploadComplete verifies the assembled upload against the
+// client-declared sha256, dedups it against file_objects, and inserts the
+// logical files row pointing at the (possibly pre-existing) object.
+func handleUploadComplete(w http.ResponseWriter, r *http.Request, database *db.DB, fileStorage *files.Storage, uploadID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req uploadCompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Filename == "" {
+		http.Error(w, "filename is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := auth.GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	object, err := fileStorage.CompleteUpload(uploadID, req.SHA256)
+	if err != nil {
+		log.Printf("Error completing upload %s: %v", uploadID, err)
+		http.Error(w, "Upload verification failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	_, err = database.ExecContext(ctx, `
+		INSERT INTO file_objects (sha256, size, mime_type, refcount)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (sha256) DO UPDATE SET refcount = file_objects.refcount + 1
+	`, object.SHA256, object.Size, req.MimeType)
+	if err != nil {
+		log.Printf("Error recording file object %s: %v", object.SHA256, err)
+		http.Error(w, "Failed to record upload", http.StatusInternalServerError)
+		return
+	}
+
+	var fileID string
+	err = database.QueryRowContext(ctx, `
+		INSERT INTO files (user_id, filename, directory, original_filename, mime_type, file_size, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, user.ID, req.Filename, req.Directory, req.Filename, req.MimeType, object.Size, object.SHA256).Scan(&fileID)
+	if err != nil {
+		log.Printf("Error creating file row for upload %s: %v", uploadID, err)
+		http.Error(w, "Failed to record upload", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"fileId":         fileID,
+		"sha256":         object.SHA256,
+		"size":           object.Size,
+		"alreadyExisted": object.AlreadyExisted,
+	})
+}
+
+// parseContentRange parses a "bytes {start}-{end}/{total}" Content-Range
+// header as sent by chunked upload clients.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	if header == "" {
+		return 0, 0, 0, fmt.Errorf("Content-Range header is required")
+	}
+	spec := strings.TrimPrefix(header, "bytes ")
+	rangePart, totalPart, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range %q", header)
+	}
+	startPart, endPart, ok := strings.Cut(rangePart, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range %q", header)
+	}
+
+	start, err = strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid range start: %w", err)
+	}
+	end, err = strconv.ParseInt(endPart, 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid range end: %w", err)
+	}
+	if totalPart != "*" {
+		total, err = strconv.ParseInt(totalPart, 10, 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid range total: %w", err)
+		}
+	}
+
+	return start, end, total, nil
+}
+
+// byteRange is a single inclusive [start, end] byte range parsed from a
+// Range header.
+type byteRange struct {
+	start, end int64
+}
+
+// parseByteRanges parses an RFC 7233 "Range: bytes=..." header (one or
+// more comma-separated ranges, including suffix ranges like "bytes=-500")
+// against a resource of the given size.
+func parseByteRanges(header string, size int64) ([]byteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, fmt.Errorf("unsupported range unit in %q", header)
+	}
+
+	var ranges []byteRange
+	for _, part := range strings.Split(strings.TrimPrefix(header, prefix), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		startPart, endPart, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("malformed range %q", part)
+		}
+
+		var start, end int64
+		if startPart == "" {
+			// Suffix range: last N bytes.
+			n, err := strconv.ParseInt(endPart, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid suffix range %q: %w", part, err)
+			}
+			if n > size {
+				n = size
+			}
+			start, end = size-n, size-1
+		} else {
+			s, err := strconv.ParseInt(startPart, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range start in %q: %w", part, err)
+			}
+			start = s
+			if endPart == "" {
+				end = size - 1
+			} else {
+				e, err := strconv.ParseInt(endPart, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid range end in %q: %w", part, err)
+				}
+				end = e
+			}
+		}
+
+		if start < 0 || start >= size || end < start {
+			return nil, fmt.Errorf("range %q out of bounds for size %d", part, size)
+		}
+		if end >= size {
+			end = size - 1
+		}
+		ranges = append(ranges, byteRange{start: start, end: end})
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no satisfiable ranges in %q", header)
+	}
+	return ranges, nil
+}
+
+// handleStreamFile serves GET /files/stream/{id}, authorized by a
+// streamurl-signed ?expires=&sig= pair rather than an Authorization header.
+// It implements RFC 7233 single- and multi-range requests, streaming from
+// Telegram (fetching only the requested byte ranges) when the file has a
+// telegram_file_id, and falling back to the local object store otherwise.
+func handleStreamFile(w http.ResponseWriter, r *http.Request, database *db.DB, fileStorage *files.Storage, telegramClient *telegram.Client, signer *streamurl.Signer) {
+	fileID := strings.TrimPrefix(r.URL.Path, "/files/stream/")
+	if fileID == "" {
+		http.Error(w, "File ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := signer.Verify(fileID, r.URL.Query().Get("expires"), r.URL.Query().Get("sig")); err != nil {
+		http.Error(w, "Invalid or expired signature", http.StatusForbidden)
+		return
+	}
+
+	var filename, mimeType string
+	var telegramFileID, sha256Hash sql.NullString
+	var fileSize int64
+	err := database.QueryRowContext(r.Context(), `
+		SELECT filename, mime_type, telegram_file_id, file_size, sha256
+		FROM files WHERE id = $1`,
+		fileID).Scan(&filename, &mimeType, &telegramFileID, &fileSize, &sha256Hash)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if telegramFileID.Valid && telegramClient != nil {
+		streamFromTelegram(w, r, telegramClient, telegramFileID.String, fileSize, mimeType)
+		return
+	}
+
+	if !sha256Hash.Valid {
+		http.Error(w, "File has no backing storage", http.StatusNotFound)
+		return
+	}
+	object, err := fileStorage.OpenObject(sha256Hash.String)
+	if err != nil {
+		http.Error(w, "File not found on disk", http.StatusNotFound)
+		return
+	}
+	defer object.Close()
+
+	// http.ServeContent already implements RFC 7233 (including multi-range
+	// multipart/byteranges responses) given a ReadSeeker, which is all the
+	// local object store needs.
+	osFile, ok := object.(*os.File)
+	if !ok {
+		http.Error(w, "File has no backing storage", http.StatusInternalServerError)
+		return
+	}
+	info, err := osFile.Stat()
+	if err != nil {
+		http.Error(w, "Error reading file", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mimeType)
+	http.ServeContent(w, r, filename, info.ModTime(), osFile)
+}
+
+// streamFromTelegram serves a file backed by Telegram, fetching only the
+// requested byte range(s) from Telegram's file CDN rather than the whole
+// object. Multiple ranges are served as a multipart/byteranges response,
+// each part fetched independently.
+func streamFromTelegram(w http.ResponseWriter, r *http.Request, client *telegram.Client, telegramFileID string, fileSize int64, mimeType string) {
+	info, err := client.GetFile(telegramFileID)
+	if err != nil {
+		http.Error(w, "Failed to resolve file with Telegram: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	size := fileSize
+	if size <= 0 {
+		size = info.FileSize
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" {
+		reader, err := client.OpenRange(info.FilePath, 0, size-1)
+		if err != nil {
+			http.Error(w, "Failed to stream file: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer reader.Close()
+		w.Header().Set("Content-Type", mimeType)
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, reader)
+		return
+	}
+
+	ranges, err := parseByteRanges(rangeHeader, size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, "Invalid Range: "+err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	if len(ranges) == 1 {
+		rg := ranges[0]
+		reader, err := client.OpenRange(info.FilePath, rg.start, rg.end)
+		if err != nil {
+			http.Error(w, "Failed to stream range: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer reader.Close()
+		w.Header().Set("Content-Type", mimeType)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rg.start, rg.end, size))
+		w.Header().Set("Content-Length", strconv.FormatInt(rg.end-rg.start+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		io.Copy(w, reader)
+		return
+	}
+
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusPartialContent)
+	defer mw.Close()
+
+	for _, rg := range ranges {
+		partHeader := textproto.MIMEHeader{}
+		partHeader.Set("Content-Type", mimeType)
+		partHeader.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rg.start, rg.end, size))
+		part, err := mw.CreatePart(partHeader)
+		if err != nil {
+			return
+		}
+
+		reader, err := client.OpenRange(info.FilePath, rg.start, rg.end)
+		if err != nil {
+			return
+		}
+		io.Copy(part, reader)
+		reader.Close()
+	}
+}
+
+// streamUploadChunkSpec is one entry in the manifest POSTed to
+// /files/stream-upload/init.
+type streamUploadChunkSpec struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// streamUploadChunkPlacement is a manifest chunk with its reserved byte
+// offset filled in, so chunks can be uploaded out of order or in parallel.
+// Received is flipped to true once handleStreamUploadChunk has written and
+// hash-verified that chunk, so handleStreamUploadComplete can refuse to
+// assemble an upload that's missing or skipped part of its manifest.
+type streamUploadChunkPlacement struct {
+	SHA256   string `json:"sha256"`
+	Offset   int64  `json:"offset"`
+	Size     int64  `json:"size"`
+	Received bool   `json:"received"`
+}
+
+type streamUploadInitRequest struct {
+	Filename  string                  `json:"filename"`
+	MimeType  string                  `json:"mimeType"`
+	Directory string                  `json:"directory"`
+	Chunks    []streamUploadChunkSpec `json:"chunks"`
+}
+
+// handleStreamUploadInit reserves an upload and computes each manifest
+// chunk's byte offset up front, persisting the manifest so chunk PUTs can
+// arrive in any order.
+func handleStreamUploadInit(w http.ResponseWriter, r *http.Request, database *db.DB, fileStorage *files.Storage) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := auth.GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req streamUploadInitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Filename == "" || len(req.Chunks) == 0 {
+		http.Error(w, "filename and chunks are required", http.StatusBadRequest)
+		return
+	}
+	if req.Directory == "" {
+		req.Directory = "/"
+	}
+
+	uploadID, err := fileStorage.InitUpload()
+	if err != nil {
+		log.Printf("Error initializing stream upload: %v", err)
+		http.Error(w, "Failed to initialize upload", http.StatusInternalServerError)
+		return
+	}
+
+	var totalSize int64
+	placements := make([]streamUploadChunkPlacement, len(req.Chunks))
+	for i, chunk := range req.Chunks {
+		placements[i] = streamUploadChunkPlacement{SHA256: chunk.SHA256, Offset: totalSize, Size: chunk.Size}
+		totalSize += chunk.Size
+	}
+
+	if err := fileStorage.PreallocateUpload(uploadID, totalSize); err != nil {
+		log.Printf("Error preallocating stream upload %s: %v", uploadID, err)
+		http.Error(w, "Failed to initialize upload", http.StatusInternalServerError)
+		return
+	}
+
+	manifestJSON, err := json.Marshal(placements)
+	if err != nil {
+		http.Error(w, "Failed to build upload manifest", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = database.ExecContext(r.Context(), `
+		INSERT INTO upload_manifests (upload_id, user_id, filename, mime_type, directory, chunks)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uploadID, user.ID, req.Filename, req.MimeType, req.Directory, manifestJSON)
+	if err != nil {
+		log.Printf("Error recording upload manifest %s: %v", uploadID, err)
+		http.Error(w, "Failed to record upload manifest", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"uploadId": uploadID,
+		"chunks":   placements,
+	})
+}
+
+// handleStreamUploadChunk writes one manifest chunk at its reserved offset,
+// verifies it against the sha256 declared for that chunk in the URL, and
+// marks it received in the persisted manifest so handleStreamUploadComplete
+// can confirm every chunk arrived before assembling the upload.
+func handleStreamUploadChunk(w http.ResponseWriter, r *http.Request, database *db.DB, fileStorage *files.Storage, uploadID, chunkSHA256 string) {
+	placements, err := loadUploadManifest(r.Context(), database, uploadID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	index := -1
+	for i := range placements {
+		if placements[i].SHA256 == chunkSHA256 {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		http.Error(w, "chunk not part of this upload's manifest", http.StatusNotFound)
+		return
+	}
+
+	hasher := sha256.New()
+	if _, err := fileStorage.WriteChunk(uploadID, placements[index].Offset, io.TeeReader(r.Body, hasher)); err != nil {
+		log.Printf("Error writing stream chunk %s for upload %s: %v", chunkSHA256, uploadID, err)
+		http.Error(w, "Failed to write chunk", http.StatusInternalServerError)
+		return
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != chunkSHA256 {
+		http.Error(w, fmt.Sprintf("chunk sha256 mismatch: declared %s, got %s", chunkSHA256, got), http.StatusBadRequest)
+		return
+	}
+
+	if err := markChunkReceived(r.Context(), database, uploadID, chunkSHA256); err != nil {
+		log.Printf("Error persisting received chunk %s for upload %s: %v", chunkSHA256, uploadID, err)
+		http.Error(w, "Failed to record chunk", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"sha256": chunkSHA256, "received": true})
+}
+
+// streamUploadManifestDB is satisfied by both *db.DB and *sqlx.Tx, letting
+// loadUploadManifest/saveUploadManifest run either directly or inside a
+// transaction.
+type streamUploadManifestDB interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// markChunkReceived flips chunkSHA256's Received flag in uploadID's
+// manifest. It does the read-modify-write inside a transaction that locks
+// the manifest row with SELECT ... FOR UPDATE, so two chunks for the same
+// upload finishing concurrently can't both read the manifest before
+// either writes it back and have the second writer's stale copy clobber
+// the first writer's Received flag.
+func markChunkReceived(ctx context.Context, database *db.DB, uploadID, chunkSHA256 string) error {
+	tx, err := database.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var manifestJSON []byte
+	if err := tx.QueryRowContext(ctx,
+		"SELECT chunks FROM upload_manifests WHERE upload_id = $1 FOR UPDATE", uploadID,
+	).Scan(&manifestJSON); err != nil {
+		return fmt.Errorf("unknown upload ID")
+	}
+
+	var placements []streamUploadChunkPlacement
+	if err := json.Unmarshal(manifestJSON, &placements); err != nil {
+		return fmt.Errorf("corrupt upload manifest: %w", err)
+	}
+
+	found := false
+	for i := range placements {
+		if placements[i].SHA256 == chunkSHA256 {
+			placements[i].Received = true
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("chunk not part of this upload's manifest")
+	}
+
+	if err := saveUploadManifest(ctx, tx, uploadID, placements); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func loadUploadManifest(ctx context.Context, database streamUploadManifestDB, uploadID string) ([]streamUploadChunkPlacement, error) {
+	var manifestJSON []byte
+	if err := database.QueryRowContext(ctx,
+		"SELECT chunks FROM upload_manifests WHERE upload_id = $1", uploadID,
+	).Scan(&manifestJSON); err != nil {
+		return nil, fmt.Errorf("unknown upload ID")
+	}
+
+	var placements []streamUploadChunkPlacement
+	if err := json.Unmarshal(manifestJSON, &placements); err != nil {
+		return nil, fmt.Errorf("corrupt upload manifest: %w", err)
+	}
+	return placements, nil
+}
+
+func saveUploadManifest(ctx context.Context, database streamUploadManifestDB, uploadID string, placements []streamUploadChunkPlacement) error {
+	manifestJSON, err := json.Marshal(placements)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload manifest: %w", err)
+	}
+	_, err = database.ExecContext(ctx, "UPDATE upload_manifests SET chunks = $1 WHERE upload_id = $2", manifestJSON, uploadID)
+	return err
+}
+
+// handleStreamUploadComplete assembles the manifest-driven upload into the
+// content-addressed object store, inserts the files row, and — when
+// Telegram storage is configured — also pushes the assembled file to
+// Telegram so it can later be streamed via streamFromTelegram.
+func handleStreamUploadComplete(w http.ResponseWriter, r *http.Request, database *db.DB, fileStorage *files.Storage, telegramClient *telegram.Client, uploadID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := auth.GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	var manifestUserID, filename, mimeType, directory string
+	err = database.QueryRowContext(ctx,
+		"SELECT user_id, filename, mime_type, directory FROM upload_manifests WHERE upload_id = $1",
+		uploadID,
+	).Scan(&manifestUserID, &filename, &mimeType, &directory)
+	if err != nil {
+		http.Error(w, "Unknown upload ID", http.StatusNotFound)
+		return
+	}
+	if manifestUserID != user.ID {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	placements, err := loadUploadManifest(ctx, database, uploadID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	for _, placement := range placements {
+		if !placement.Received {
+			http.Error(w, fmt.Sprintf("chunk %s was never received", placement.SHA256), http.StatusBadRequest)
+			return
+		}
+	}
+
+	object, err := fileStorage.CompleteUpload(uploadID, "")
+	if err != nil {
+		log.Printf("Error completing stream upload %s: %v", uploadID, err)
+		http.Error(w, "Failed to assemble upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = database.ExecContext(ctx, `
+		INSERT INTO file_objects (sha256, size, mime_type, refcount)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (sha256) DO UPDATE SET refcount = file_objects.refcount + 1
+	`, object.SHA256, object.Size, mimeType)
+	if err != nil {
+		log.Printf("Error recording file object %s: %v", object.SHA256, err)
+		http.Error(w, "Failed to record upload", http.StatusInternalServerError)
+		return
+	}
+
+	var telegramFileID, telegramFileUniqueID *string
+	if chatID := os.Getenv("TELEGRAM_STORAGE_CHAT_ID"); telegramClient != nil && chatID != "" {
+		if reader, err := fileStorage.OpenObject(object.SHA256); err == nil {
+			doc, err := telegramClient.UploadDocument(chatID, filename, reader)
+			reader.Close()
+			if err != nil {
+				log.Printf("Error uploading assembled file %s to Telegram: %v", object.SHA256, err)
+			} else {
+				telegramFileID = &doc.FileID
+				telegramFileUniqueID = &doc.FileUniqueID
+			}
+		}
+	}
+
+	var fileID string
+	err = database.QueryRowContext(ctx, `
+		INSERT INTO files (user_id, filename, directory, original_filename, mime_type, file_size,
+		                    sha256, telegram_file_id, telegram_file_unique_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, user.ID, filename, directory, filename, mimeType, object.Size,
+		object.SHA256, telegramFileID, telegramFileUniqueID).Scan(&fileID)
+	if err != nil {
+		log.Printf("Error creating file row for stream upload %s: %v", uploadID, err)
+		http.Error(w, "Failed to record upload", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := database.ExecContext(ctx, "DELETE FROM upload_manifests WHERE upload_id = $1", uploadID); err != nil {
+		log.Printf("Error cleaning up upload manifest %s: %v", uploadID, err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"fileId": fileID,
+		"sha256": object.SHA256,
+		"size":   object.Size,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/jmoiron/sqlx/reflectx"
@@ -39,37 +40,68 @@ func NewDB(databaseURL string) (*DB, error) {
 	return &DB{db}, nil
 }
 
-// toSnakeCase converts a string from PascalCase/camelCase to snake_case
-// Handles acronyms properly: ID -> id, UserID -> user_id, HTTPSConnection -> https_connection
+// toSnakeCase converts a string from PascalCase/camelCase to snake_case,
+// treating a run of uppercase letters as a single acronym: UserID -> user_id,
+// HTTPSConnection -> https_connection. A underscore goes before an
+// uppercase rune when either the previous rune is lowercase or a digit (a
+// new word is starting), or the previous rune is uppercase and the next
+// one is lowercase (the acronym just ended and a new word is starting on
+// this rune). Two acronyms butted directly against each other with no
+// lowercase letter between them are indistinguishable from a single
+// longer acronym by casing alone, so they stay glued together:
+// UserIDHTTPRequest -> user_idhttp_request, not user_id_http_request.
 func toSnakeCase(s string) string {
 	if s == "" {
 		return ""
 	}
-	
+
 	var result strings.Builder
 	runes := []rune(s)
-	
-	for i := 0; i < len(runes); i++ {
-		r := runes[i]
-		
-		// Add underscore before uppercase letter if:
-		// 1. Not the first character
-		// 2. Previous character is lowercase or next character is lowercase (handles acronyms)
+
+	for i, r := range runes {
 		if i > 0 && r >= 'A' && r <= 'Z' {
-			prevIsLower := runes[i-1] >= 'a' && runes[i-1] <= 'z'
+			prev := runes[i-1]
+			prevIsLowerOrDigit := (prev >= 'a' && prev <= 'z') || (prev >= '0' && prev <= '9')
+			prevIsUpper := prev >= 'A' && prev <= 'Z'
 			nextIsLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
-			
-			if prevIsLower || nextIsLower {
+
+			if prevIsLowerOrDigit || (prevIsUpper && nextIsLower) {
 				result.WriteRune('_')
 			}
 		}
-		
+
 		result.WriteRune(r)
 	}
-	
+
 	return strings.ToLower(result.String())
 }
 
+// toCamelCase converts a snake_case string back to PascalCase, the reverse
+// of toSnakeCase, for mapping database column names back to struct field
+// names. Each underscore-separated segment is title-cased and concatenated;
+// "user_id" -> "UserId" and "https_connection" -> "HttpsConnection" (the
+// original acronym casing isn't recoverable from snake_case alone, so this
+// is not a strict inverse of toSnakeCase, only a round-trip to *a* valid
+// exported Go identifier).
+func toCamelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	segments := strings.Split(s, "_")
+	var result strings.Builder
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		runes := []rune(segment)
+		result.WriteRune(unicode.ToUpper(runes[0]))
+		result.WriteString(strings.ToLower(string(runes[1:])))
+	}
+
+	return result.String()
+}
+
 // Ping checks if the database connection is alive
 func (db *DB) Ping(ctx context.Context) error {
 	return db.PingContext(ctx)
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -46,10 +47,20 @@ func TestToSnakeCase(t *testing.T) {
 			expected: "user_name",
 		},
 		{
-			name:     "Complex example",
+			// Known limitation: two acronyms butted directly against each
+			// other (no lowercase letter between them) are indistinguishable
+			// from a single longer acronym by casing alone, so "ID" and
+			// "HTTP" stay glued together. The acronym-to-word boundary rule
+			// still correctly splits the run off from the following word.
+			name:     "Adjacent acronyms",
 			input:    "UserIDHTTPRequest",
 			expected: "user_idhttp_request",
 		},
+		{
+			name:     "Digit before acronym",
+			input:    "Entry3ID",
+			expected: "entry3_id",
+		},
 		{
 			name:     "Single word",
 			input:    "user",
@@ -87,8 +98,91 @@ func TestToSnakeCaseConsistency(t *testing.T) {
 	input := "UserName"
 	first := toSnakeCase(input)
 	second := toSnakeCase(first)
-	
+
 	if first != second {
 		t.Errorf("toSnakeCase not idempotent: first=%q, second=%q", first, second)
 	}
 }
+
+func TestToCamelCase(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Simple",
+			input:    "user_name",
+			expected: "UserName",
+		},
+		{
+			name:     "Single segment",
+			input:    "user",
+			expected: "User",
+		},
+		{
+			name:     "Acronym segment",
+			input:    "user_id",
+			expected: "UserId",
+		},
+		{
+			name:     "Empty string",
+			input:    "",
+			expected: "",
+		},
+		{
+			name:     "Leading underscore",
+			input:    "_user_name",
+			expected: "UserName",
+		},
+		{
+			name:     "Digit segment",
+			input:    "entry3_id",
+			expected: "Entry3Id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := toCamelCase(tt.input)
+			if result != tt.expected {
+				t.Errorf("toCamelCase(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToSnakeCaseToCamelCaseRoundTrip(t *testing.T) {
+	// toCamelCase(toSnakeCase(s)) only round-trips to the original for names
+	// that don't contain multi-letter acronyms, since snake_case forgets
+	// where an acronym started; single-capital field names like "UserId"
+	// survive.
+	for _, field := range []string{"UserId", "Name", "CreatedAt", "IsActiveUser"} {
+		snake := toSnakeCase(field)
+		camel := toCamelCase(snake)
+		if camel != field {
+			t.Errorf("round trip failed: toCamelCase(toSnakeCase(%q)) = %q via %q", field, camel, snake)
+		}
+	}
+}
+
+func FuzzToSnakeCase(f *testing.F) {
+	for _, seed := range []string{"UserID", "HTTPSConnection", "UserIDHTTPRequest", "createdAt", "", "user_name", "Entry3ID"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		result := toSnakeCase(s)
+
+		// toSnakeCase must be idempotent: re-running it on its own output
+		// changes nothing.
+		if again := toSnakeCase(result); again != result {
+			t.Errorf("toSnakeCase(%q) = %q, not idempotent: toSnakeCase(%q) = %q", s, result, result, again)
+		}
+
+		// The result must already be lowercase.
+		if strings.ToLower(result) != result {
+			t.Errorf("toSnakeCase(%q) = %q, expected an all-lowercase result", s, result)
+		}
+	})
+}
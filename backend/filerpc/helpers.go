@@ -0,0 +1,103 @@
+package filerpc
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/aleksandr/lifetrack/backend/filerpc/pb"
+)
+
+// scanFileEntry scans a files row (in the column order used throughout this
+// package) into a pb.FileEntry.
+func scanFileEntry(scanner interface{ Scan(...interface{}) error }) (*pb.FileEntry, error) {
+	var id, filename, directory, originalFilename, mimeType, storagePath string
+	var fileSize int64
+	var tags pq.StringArray
+	var description, fingerprintAlgorithm, fingerprintValue sql.NullString
+	var createdAt, updatedAt time.Time
+
+	err := scanner.Scan(
+		&id, &filename, &directory, &originalFilename, &mimeType, &fileSize,
+		&storagePath, &tags, &description, &fingerprintAlgorithm, &fingerprintValue,
+		&createdAt, &updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &pb.FileEntry{
+		Id:               id,
+		Filename:         filename,
+		Directory:        directory,
+		OriginalFilename: originalFilename,
+		MimeType:         mimeType,
+		FileSize:         fileSize,
+		StoragePath:      storagePath,
+		Tags:             []string(tags),
+		CreatedAt:        timestamppb.New(createdAt),
+		UpdatedAt:        timestamppb.New(updatedAt),
+	}
+	if description.Valid {
+		entry.Description = description.String
+	}
+	if fingerprintAlgorithm.Valid {
+		entry.FingerprintAlgorithm = fingerprintAlgorithm.String
+	}
+	if fingerprintValue.Valid {
+		entry.Fingerprint = fingerprintValue.String
+	}
+
+	return entry, nil
+}
+
+// directoryDepth returns a path's depth in the materialized-path tree: "/"
+// is depth 0, "/a" is depth 1, "/a/b" is depth 2, and so on. Kept in sync
+// with graph.directoryDepth since both packages maintain the same
+// directories.depth invariant independently.
+func directoryDepth(path string) int {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return 0
+	}
+	return strings.Count(trimmed, "/") + 1
+}
+
+// dbExecer is satisfied by *db.DB, letting ensureDirectoryPath be reused
+// without depending on a transaction type this package doesn't otherwise need.
+type dbExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ensureDirectoryPath makes sure a directories row, with the correct depth,
+// exists for path and every one of its ancestors. See graph.ensureDirectoryPath
+// for why this needs to walk the whole chain rather than just inserting path.
+func ensureDirectoryPath(ctx context.Context, exec dbExecer, userID string, path string) error {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	current := ""
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		current += "/" + segment
+		_, err := exec.ExecContext(ctx, `
+			INSERT INTO directories (user_id, path, depth) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, path) DO NOTHING`,
+			userID, current, directoryDepth(current))
+		if err != nil {
+			return fmt.Errorf("failed to ensure directory %s exists: %w", current, err)
+		}
+	}
+	return nil
+}
+
+func bytesReader(b []byte) io.Reader {
+	return bytes.NewReader(b)
+}
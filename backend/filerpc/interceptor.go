@@ -0,0 +1,76 @@
+package filerpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/aleksandr/lifetrack/backend/auth"
+)
+
+// AuthInterceptor validates the bearer token carried in the "authorization"
+// metadata entry the same way auth.Middleware does for HTTP requests, and
+// stores the resulting user in context under auth.UserContextKey so
+// handlers can keep using auth.GetUserFromContext unchanged.
+type AuthInterceptor struct {
+	Auth *auth.Service
+}
+
+// Unary returns a grpc.UnaryServerInterceptor that authenticates every call.
+func (i *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := i.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns a grpc.StreamServerInterceptor that authenticates every
+// call, including ListEntries/UploadFile/DownloadFile.
+func (i *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (i *AuthInterceptor) authenticate(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+
+	token := strings.TrimPrefix(values[0], "Bearer ")
+	user, err := i.Auth.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return context.WithValue(ctx, auth.UserContextKey, user), nil
+}
+
+// authenticatedStream overrides Context() so handlers see the user-bearing
+// context produced by authenticate, since grpc.ServerStream.Context() is
+// otherwise read-only.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,605 @@
+// Package filerpc exposes the same file/directory operations as the
+// GraphQL resolvers in graph/file.resolvers.go over gRPC, for clients
+// (mobile apps, sync daemons) that would rather speak protobuf than carry a
+// GraphQL client. It talks to the database and object store directly
+// instead of depending on the graph package, the same way the trash
+// package does, so neither side has to export its unexported resolver
+// types to be reused.
+//
+// file_service.proto is the source of truth for the wire types and the
+// FileService interface; pb is generated from it and is not checked in
+// (matching graph/model, which gqlgen generates from schema.graphql the
+// same way). Regenerate it after editing the .proto with:
+//
+//	go generate ./filerpc/...
+//
+// which requires protoc plus the protoc-gen-go plugin, pinned to this
+// module's google.golang.org/protobuf version, and the latest
+// protoc-gen-go-grpc plugin:
+//
+//	go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.36.11
+//	go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest
+//
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative file_service.proto
+package filerpc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/aleksandr/lifetrack/backend/auth"
+	"github.com/aleksandr/lifetrack/backend/db"
+	"github.com/aleksandr/lifetrack/backend/files"
+	"github.com/aleksandr/lifetrack/backend/filerpc/pb"
+	"github.com/aleksandr/lifetrack/backend/fingerprint"
+)
+
+// listEntriesPageSize is how many rows ListEntries sends per batch.
+const listEntriesPageSize = 1000
+
+// FileService implements pb.FileServiceServer against the shared SQL layer
+// and object store.
+type FileService struct {
+	pb.UnimplementedFileServiceServer
+	DB      *db.DB
+	Storage *files.Storage
+}
+
+// NewFileService constructs a FileService backed by database and storage.
+func NewFileService(database *db.DB, storage *files.Storage) *FileService {
+	return &FileService{DB: database, Storage: storage}
+}
+
+func (s *FileService) Files(ctx context.Context, req *pb.FilesRequest) (*pb.FilesResponse, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	query := `SELECT id, filename, directory, original_filename, mime_type, file_size,
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at
+	          FROM files WHERE user_id = $1 AND deleted_at IS NULL`
+	args := []interface{}{currentUser.ID}
+
+	if req.Directory != "" {
+		args = append(args, req.Directory)
+		query += fmt.Sprintf(" AND directory = $%d", len(args))
+	}
+	if req.MimeType != "" {
+		args = append(args, req.MimeType)
+		query += fmt.Sprintf(" AND mime_type = $%d", len(args))
+	}
+	if req.SearchQuery != "" {
+		args = append(args, "%"+req.SearchQuery+"%")
+		query += fmt.Sprintf(" AND filename ILIKE $%d", len(args))
+	}
+	if len(req.Tags) > 0 {
+		args = append(args, pq.Array(req.Tags))
+		query += fmt.Sprintf(" AND tags && $%d", len(args))
+	}
+	query += " ORDER BY filename"
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = 100
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+	args = append(args, int(req.Offset))
+	query += fmt.Sprintf(" OFFSET $%d", len(args))
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*pb.FileEntry{}
+	for rows.Next() {
+		entry, err := scanFileEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	var totalCount int
+	if err := s.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM files WHERE user_id = $1 AND deleted_at IS NULL", currentUser.ID,
+	).Scan(&totalCount); err != nil {
+		return nil, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	return &pb.FilesResponse{Files: entries, TotalCount: int32(totalCount)}, nil
+}
+
+func (s *FileService) File(ctx context.Context, req *pb.FileRequest) (*pb.FileEntry, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT id, filename, directory, original_filename, mime_type, file_size,
+		storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at
+		FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`,
+		req.Id, currentUser.ID)
+
+	entry, err := scanFileEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan file: %w", err)
+	}
+	return entry, nil
+}
+
+func (s *FileService) Directories(ctx context.Context, req *pb.DirectoriesRequest) (*pb.DirectoriesResponse, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	parent := "/"
+	if req.ParentPath != "" {
+		parent = req.ParentPath
+	}
+	pattern := parent
+	if !strings.HasSuffix(pattern, "/") {
+		pattern += "/"
+	}
+	pattern += "%"
+
+	rows, err := s.DB.QueryContext(ctx, `
+		WITH children AS (
+			SELECT path, depth FROM directories
+			WHERE user_id = $1 AND deleted_at IS NULL AND path LIKE $2 AND depth = $3
+		)
+		SELECT
+			c.path,
+			c.depth,
+			COALESCE(sub.names, '{}'),
+			COALESCE(agg.file_count, 0),
+			COALESCE(agg.recursive_file_count, 0),
+			COALESCE(agg.total_size, 0)
+		FROM children c
+		LEFT JOIN LATERAL (
+			SELECT array_agg(substring(d2.path FROM char_length(c.path) + 2)) AS names
+			FROM directories d2
+			WHERE d2.user_id = $1 AND d2.deleted_at IS NULL
+			  AND d2.path LIKE c.path || '/%' AND d2.depth = c.depth + 1
+		) sub ON true
+		LEFT JOIN LATERAL (
+			SELECT
+				COUNT(*) FILTER (WHERE directory = c.path) AS file_count,
+				COUNT(*) AS recursive_file_count,
+				COALESCE(SUM(file_size), 0) AS total_size
+			FROM files
+			WHERE user_id = $1 AND deleted_at IS NULL
+			  AND (directory = c.path OR directory LIKE c.path || '/%')
+		) agg ON true
+		ORDER BY c.path`,
+		currentUser.ID, pattern, directoryDepth(parent)+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query directories: %w", err)
+	}
+	defer rows.Close()
+
+	directories := []*pb.DirectoryEntry{}
+	for rows.Next() {
+		var path string
+		var depth, fileCount, recursiveFileCount int32
+		var totalSize int64
+		var subdirs pq.StringArray
+		if err := rows.Scan(&path, &depth, &subdirs, &fileCount, &recursiveFileCount, &totalSize); err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+		directories = append(directories, &pb.DirectoryEntry{
+			Path:               path,
+			Parent:             parent,
+			Subdirectories:     []string(subdirs),
+			FileCount:          fileCount,
+			RecursiveFileCount: recursiveFileCount,
+			TotalSize:          totalSize,
+			Depth:              depth,
+		})
+	}
+
+	return &pb.DirectoriesResponse{Directories: directories}, nil
+}
+
+func (s *FileService) CreateFile(ctx context.Context, req *pb.CreateFileRequest) (*pb.FileEntry, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	row := s.DB.QueryRowContext(ctx, `
+		INSERT INTO files (user_id, filename, directory, original_filename, mime_type, file_size, storage_path, tags, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, filename, directory, original_filename, mime_type, file_size,
+		storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at`,
+		currentUser.ID, req.Filename, req.Directory, req.OriginalFilename, req.MimeType, req.FileSize,
+		req.StoragePath, pq.Array(req.Tags), req.Description)
+
+	entry, err := scanFileEntry(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	return entry, nil
+}
+
+func (s *FileService) UpdateFile(ctx context.Context, req *pb.UpdateFileRequest) (*pb.FileEntry, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	updates := []string{}
+	args := []interface{}{req.Id, currentUser.ID}
+	argCount := 2
+
+	if req.Filename != "" {
+		argCount++
+		updates = append(updates, fmt.Sprintf("filename = $%d", argCount))
+		args = append(args, req.Filename)
+	}
+	if req.Directory != "" {
+		argCount++
+		updates = append(updates, fmt.Sprintf("directory = $%d", argCount))
+		args = append(args, req.Directory)
+	}
+	if req.UpdateTags {
+		argCount++
+		updates = append(updates, fmt.Sprintf("tags = $%d", argCount))
+		args = append(args, pq.Array(req.Tags))
+	}
+	if req.Description != "" {
+		argCount++
+		updates = append(updates, fmt.Sprintf("description = $%d", argCount))
+		args = append(args, req.Description)
+	}
+
+	if len(updates) == 0 {
+		return s.File(ctx, &pb.FileRequest{Id: req.Id})
+	}
+
+	query := "UPDATE files SET " + joinUpdates(updates) + `, updated_at = NOW()
+	          WHERE id = $1 AND user_id = $2
+	          RETURNING id, filename, directory, original_filename, mime_type, file_size,
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at`
+
+	entry, err := scanFileEntry(s.DB.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update file: %w", err)
+	}
+	return entry, nil
+}
+
+func (s *FileService) DeleteFile(ctx context.Context, req *pb.DeleteFileRequest) (*pb.DeleteFileResponse, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	result, err := s.DB.ExecContext(ctx, `
+		UPDATE files SET deleted_at = NOW(), purge_storage = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL`,
+		req.RemoveFromStorage, req.Id, currentUser.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to trash file: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check trash: %w", err)
+	}
+	return &pb.DeleteFileResponse{Success: rows > 0}, nil
+}
+
+func (s *FileService) MoveFile(ctx context.Context, req *pb.MoveFileRequest) (*pb.FileEntry, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	if err := ensureDirectoryPath(ctx, s.DB, currentUser.ID, req.NewDirectory); err != nil {
+		return nil, fmt.Errorf("failed to move file: %w", err)
+	}
+
+	entry, err := scanFileEntry(s.DB.QueryRowContext(ctx, `
+		UPDATE files SET directory = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+		RETURNING id, filename, directory, original_filename, mime_type, file_size,
+		storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at`,
+		req.NewDirectory, req.Id, currentUser.ID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to move file: %w", err)
+	}
+	return entry, nil
+}
+
+func (s *FileService) CreateDirectory(ctx context.Context, req *pb.CreateDirectoryRequest) (*pb.DirectoryEntry, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	if err := ensureDirectoryPath(ctx, s.DB, currentUser.ID, req.Path); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	return &pb.DirectoryEntry{
+		Path:           req.Path,
+		Subdirectories: []string{},
+		Depth:          int32(directoryDepth(req.Path)),
+	}, nil
+}
+
+func (s *FileService) DeleteDirectory(ctx context.Context, req *pb.DeleteDirectoryRequest) (*pb.DeleteDirectoryResponse, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	pattern := req.Path
+	if !strings.HasSuffix(pattern, "/") {
+		pattern += "/"
+	}
+	pattern += "%"
+
+	if req.Recursive {
+		if _, err := s.DB.ExecContext(ctx, `
+			UPDATE files SET deleted_at = NOW()
+			WHERE user_id = $1 AND (directory = $2 OR directory LIKE $3) AND deleted_at IS NULL`,
+			currentUser.ID, req.Path, pattern); err != nil {
+			return nil, fmt.Errorf("failed to trash directory files: %w", err)
+		}
+		if _, err := s.DB.ExecContext(ctx, `
+			UPDATE directories SET deleted_at = NOW()
+			WHERE user_id = $1 AND (path = $2 OR path LIKE $3) AND deleted_at IS NULL`,
+			currentUser.ID, req.Path, pattern); err != nil {
+			return nil, fmt.Errorf("failed to trash directory entries: %w", err)
+		}
+		return &pb.DeleteDirectoryResponse{Success: true}, nil
+	}
+
+	var fileCount int
+	if err := s.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM files WHERE user_id = $1 AND directory = $2 AND deleted_at IS NULL",
+		currentUser.ID, req.Path,
+	).Scan(&fileCount); err != nil {
+		return nil, fmt.Errorf("failed to check directory: %w", err)
+	}
+	if fileCount > 0 {
+		return nil, fmt.Errorf("directory not empty")
+	}
+
+	var subDirCount int
+	if err := s.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM directories WHERE user_id = $1 AND path LIKE $2 AND path != $3 AND deleted_at IS NULL",
+		currentUser.ID, pattern, req.Path,
+	).Scan(&subDirCount); err != nil {
+		return nil, fmt.Errorf("failed to check subdirectories: %w", err)
+	}
+	if subDirCount > 0 {
+		return nil, fmt.Errorf("directory has subdirectories")
+	}
+
+	if _, err := s.DB.ExecContext(ctx,
+		"UPDATE directories SET deleted_at = NOW() WHERE user_id = $1 AND path = $2 AND deleted_at IS NULL",
+		currentUser.ID, req.Path,
+	); err != nil {
+		return nil, fmt.Errorf("failed to trash directory entry: %w", err)
+	}
+
+	return &pb.DeleteDirectoryResponse{Success: true}, nil
+}
+
+// ListEntries pages through the caller's files ordered by filename, sending
+// batches of listEntriesPageSize rows per message so large accounts can be
+// synced without loading everything into memory at once.
+func (s *FileService) ListEntries(req *pb.ListEntriesRequest, stream pb.FileService_ListEntriesServer) error {
+	ctx := stream.Context()
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("unauthorized")
+	}
+
+	cursor := req.StartFromFilename
+	inclusive := req.InclusiveStartFrom
+
+	for {
+		comparator := ">"
+		if inclusive {
+			comparator = ">="
+		}
+
+		rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`
+			SELECT id, filename, directory, original_filename, mime_type, file_size,
+			storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at
+			FROM files WHERE user_id = $1 AND deleted_at IS NULL AND filename %s $2
+			ORDER BY filename LIMIT $3`, comparator),
+			currentUser.ID, cursor, listEntriesPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to query files: %w", err)
+		}
+
+		entries := []*pb.FileEntry{}
+		for rows.Next() {
+			entry, err := scanFileEntry(rows)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan file: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+		rows.Close()
+
+		if len(entries) == 0 {
+			return nil
+		}
+		if err := stream.Send(&pb.ListEntriesResponse{Entries: entries}); err != nil {
+			return err
+		}
+
+		cursor = entries[len(entries)-1].Filename
+		inclusive = false
+		if len(entries) < listEntriesPageSize {
+			return nil
+		}
+	}
+}
+
+// UploadFile receives the file's content as a stream of chunks (the first
+// message also carries the CreateFileRequest metadata), writes them into
+// the content-addressed object store the same way the chunked HTTP upload
+// endpoint does, and inserts the resulting files row.
+func (s *FileService) UploadFile(stream pb.FileService_UploadFileServer) error {
+	ctx := stream.Context()
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("unauthorized")
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("failed to read upload metadata: %w", err)
+	}
+	if first.Metadata == nil {
+		return fmt.Errorf("first upload message must carry metadata")
+	}
+	metadata := first.Metadata
+
+	uploadID, err := s.Storage.InitUpload()
+	if err != nil {
+		return fmt.Errorf("failed to init upload: %w", err)
+	}
+
+	offset := int64(0)
+	writeChunk := func(content []byte) error {
+		if len(content) == 0 {
+			return nil
+		}
+		newOffset, err := s.Storage.WriteChunk(uploadID, offset, bytesReader(content))
+		if err != nil {
+			return err
+		}
+		offset = newOffset
+		return nil
+	}
+
+	if err := writeChunk(first.Content); err != nil {
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read chunk: %w", err)
+		}
+		if err := writeChunk(chunk.Content); err != nil {
+			return fmt.Errorf("failed to write chunk: %w", err)
+		}
+	}
+
+	object, err := s.Storage.CompleteUpload(uploadID, "")
+	if err != nil {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	algo, _ := fingerprint.Get(fingerprint.DefaultAlgorithm)
+
+	if _, err := s.DB.ExecContext(ctx, `
+		INSERT INTO file_objects (sha256, size, mime_type, refcount)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (sha256) DO UPDATE SET refcount = file_objects.refcount + 1`,
+		object.SHA256, object.Size, metadata.MimeType,
+	); err != nil {
+		return fmt.Errorf("failed to record file object: %w", err)
+	}
+
+	// Chunk-uploaded files are addressed by their sha256 column (the
+	// content-addressed object key), the same as the HTTP chunked upload
+	// endpoints in cmd/server/main.go — unlike CreateFile's storage_path,
+	// which is a literal filesystem path.
+	entry, err := scanFileEntry(s.DB.QueryRowContext(ctx, `
+		INSERT INTO files (user_id, filename, directory, original_filename, mime_type, file_size,
+		                    sha256, tags, description, fingerprint_algorithm, fingerprint)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, filename, directory, original_filename, mime_type, file_size,
+		storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at`,
+		currentUser.ID, metadata.Filename, metadata.Directory, metadata.OriginalFilename, metadata.MimeType,
+		object.Size, object.SHA256, pq.Array(metadata.Tags), metadata.Description, algo.Name(), object.SHA256,
+	))
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(entry)
+}
+
+// DownloadFile streams a file's content back in chunks read from the
+// content-addressed object store.
+func (s *FileService) DownloadFile(req *pb.DownloadFileRequest, stream pb.FileService_DownloadFileServer) error {
+	ctx := stream.Context()
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("unauthorized")
+	}
+
+	var sha256Hash string
+	if err := s.DB.QueryRowContext(ctx,
+		"SELECT sha256 FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+		req.Id, currentUser.ID,
+	).Scan(&sha256Hash); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("file not found")
+		}
+		return fmt.Errorf("failed to look up file: %w", err)
+	}
+
+	object, err := s.Storage.OpenObject(sha256Hash)
+	if err != nil {
+		return fmt.Errorf("failed to open file content: %w", err)
+	}
+	defer object.Close()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := object.Read(buf)
+		if n > 0 {
+			if sendErr := stream.Send(&pb.DownloadChunk{Content: append([]byte(nil), buf[:n]...)}); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file content: %w", err)
+		}
+	}
+}
+
+func joinUpdates(updates []string) string {
+	result := updates[0]
+	for _, u := range updates[1:] {
+		result += ", " + u
+	}
+	return result
+}
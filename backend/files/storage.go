@@ -1,11 +1,15 @@
 package files
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 // Config holds file storage configuration
@@ -13,107 +17,220 @@ type Config struct {
 	StorageRoot string // Root directory for file storage
 }
 
-// Storage handles file system operations for hybrid storage
+// Storage handles file system operations for content-addressable, hybrid
+// storage. Uploaded bytes live once under <root>/objects/<sha[:2]>/<sha[2:4]>/<sha>,
+// keyed by their sha256, so identical uploads are deduplicated on disk and
+// multiple `files` rows can reference the same object; in-progress uploads
+// live under <root>/tmp/<uploadID> until CompleteUpload verifies and moves
+// them into place.
 type Storage struct {
 	config Config
 }
 
+// ObjectRef describes a blob that CompleteUpload has placed in the object
+// store (or that already existed there).
+type ObjectRef struct {
+	SHA256         string
+	Size           int64
+	AlreadyExisted bool // true if an identical object was already stored
+}
+
 // NewStorage creates a new Storage instance
 func NewStorage(config Config) (*Storage, error) {
 	// Create storage root if it doesn't exist
 	if err := os.MkdirAll(config.StorageRoot, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage root: %w", err)
 	}
-	
+	if err := os.MkdirAll(filepath.Join(config.StorageRoot, "tmp"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tmp dir: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(config.StorageRoot, "objects"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create objects dir: %w", err)
+	}
+
 	return &Storage{
 		config: config,
 	}, nil
 }
 
-// SaveFile saves a file to the storage system
-func (s *Storage) SaveFile(reader io.Reader, relativePath string) error {
-	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
-	// Create parent directory if needed
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-	
-	// Create file
-	file, err := os.Create(fullPath)
+// InitUpload starts a new resumable upload and returns its ID. Chunks are
+// written to it with WriteChunk until CompleteUpload finalizes it.
+func (s *Storage) InitUpload() (string, error) {
+	uploadID := uuid.New().String()
+	file, err := os.Create(s.tempPath(uploadID))
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return "", fmt.Errorf("failed to init upload: %w", err)
 	}
 	defer file.Close()
-	
-	// Copy data
-	_, err = io.Copy(file, reader)
+	return uploadID, nil
+}
+
+// PreallocateUpload sizes an in-progress upload to its final total size
+// up front, so callers with a manifest of chunk offsets computed in
+// advance can WriteChunk them in any order rather than strictly
+// sequentially.
+func (s *Storage) PreallocateUpload(uploadID string, size int64) error {
+	file, err := os.OpenFile(s.tempPath(uploadID), os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+		return fmt.Errorf("failed to open upload %s: %w", uploadID, err)
+	}
+	defer file.Close()
+
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to preallocate upload %s: %w", uploadID, err)
 	}
-	
 	return nil
 }
 
-// GetFile returns a reader for the specified file
-func (s *Storage) GetFile(relativePath string) (io.ReadCloser, error) {
-	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
-	// Security check: ensure path is within storage root
-	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.config.StorageRoot)) {
-		return nil, fmt.Errorf("invalid path: attempted path traversal")
+// UploadOffset returns the current size of uploadID's temp file, so a
+// resuming client knows where to continue from. For a sequential upload
+// this is the number of bytes written so far; after PreallocateUpload it
+// is the preallocated size.
+func (s *Storage) UploadOffset(uploadID string) (int64, error) {
+	info, err := os.Stat(s.tempPath(uploadID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat upload %s: %w", uploadID, err)
+	}
+	return info.Size(), nil
+}
+
+// WriteChunk writes data at offset within the in-progress upload and
+// returns the offset just past the written bytes (the new total size when
+// chunks are written sequentially). Callers are expected to pass the offset
+// returned by UploadOffset, matching the git-lfs/tus-style Content-Range
+// chunked upload pattern.
+func (s *Storage) WriteChunk(uploadID string, offset int64, data io.Reader) (int64, error) {
+	file, err := os.OpenFile(s.tempPath(uploadID), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open upload %s: %w", uploadID, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek upload %s: %w", uploadID, err)
 	}
-	
-	file, err := os.Open(fullPath)
+
+	written, err := io.Copy(file, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return 0, fmt.Errorf("failed to write chunk for upload %s: %w", uploadID, err)
 	}
-	
-	return file, nil
+
+	return offset + written, nil
 }
 
-// DeleteFile removes a file from storage
-func (s *Storage) DeleteFile(relativePath string) error {
-	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
-	// Security check
-	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.config.StorageRoot)) {
-		return fmt.Errorf("invalid path: attempted path traversal")
+// CompleteUpload streams the assembled upload through sha256, verifies it
+// against the client-declared hash, and moves it into the content-addressed
+// object store. If an object with that hash already exists, the upload is
+// discarded and AlreadyExisted is set so the caller can skip storing a
+// duplicate blob.
+func (s *Storage) CompleteUpload(uploadID string, declaredSHA256 string) (*ObjectRef, error) {
+	tempPath := s.tempPath(uploadID)
+
+	actualSHA256, size, err := hashFile(tempPath)
+	if err != nil {
+		return nil, err
+	}
+	if declaredSHA256 != "" && actualSHA256 != declaredSHA256 {
+		os.Remove(tempPath)
+		return nil, fmt.Errorf("upload %s: sha256 mismatch: declared %s, got %s", uploadID, declaredSHA256, actualSHA256)
+	}
+
+	objectPath := s.objectPath(actualSHA256)
+	if _, err := os.Stat(objectPath); err == nil {
+		// Identical content is already stored; nothing left to move.
+		os.Remove(tempPath)
+		return &ObjectRef{SHA256: actualSHA256, Size: size, AlreadyExisted: true}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	}
+	if err := os.Rename(tempPath, objectPath); err != nil {
+		return nil, fmt.Errorf("failed to move upload %s into object store: %w", uploadID, err)
 	}
-	
-	if err := os.Remove(fullPath); err != nil {
-		return fmt.Errorf("failed to delete file: %w", err)
+
+	return &ObjectRef{SHA256: actualSHA256, Size: size}, nil
+}
+
+// AbortUpload discards an in-progress upload.
+func (s *Storage) AbortUpload(uploadID string) error {
+	if err := os.Remove(s.tempPath(uploadID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to abort upload %s: %w", uploadID, err)
+	}
+	return nil
+}
+
+// OpenObject returns a reader for the blob with the given sha256.
+func (s *Storage) OpenObject(sha256Hash string) (io.ReadCloser, error) {
+	file, err := os.Open(s.objectPath(sha256Hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object %s: %w", sha256Hash, err)
+	}
+	return file, nil
+}
+
+// RemoveObject unlinks the blob with the given sha256 from disk. Callers
+// must only do this once the object's file_objects.refcount has reached
+// zero.
+func (s *Storage) RemoveObject(sha256Hash string) error {
+	if err := os.Remove(s.objectPath(sha256Hash)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove object %s: %w", sha256Hash, err)
 	}
-	
 	return nil
 }
 
+func (s *Storage) tempPath(uploadID string) string {
+	return filepath.Join(s.config.StorageRoot, "tmp", uploadID)
+}
+
+// objectPath shards objects two levels deep by the start of their hash
+// (<sha[:2]>/<sha[2:4]>/<sha>) so no single directory accumulates millions
+// of entries.
+func (s *Storage) objectPath(sha256Hash string) string {
+	return filepath.Join(s.config.StorageRoot, "objects", sha256Hash[:2], sha256Hash[2:4], sha256Hash)
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s for hashing: %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
 // CreateDirectory creates a directory in the storage system
 func (s *Storage) CreateDirectory(relativePath string) error {
 	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
+
 	// Security check
 	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.config.StorageRoot)) {
 		return fmt.Errorf("invalid path: attempted path traversal")
 	}
-	
+
 	if err := os.MkdirAll(fullPath, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
-	
+
 	return nil
 }
 
 // DeleteDirectory removes a directory from storage
 func (s *Storage) DeleteDirectory(relativePath string, recursive bool) error {
 	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
+
 	// Security check
 	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.config.StorageRoot)) {
 		return fmt.Errorf("invalid path: attempted path traversal")
 	}
-	
+
 	if recursive {
 		if err := os.RemoveAll(fullPath); err != nil {
 			return fmt.Errorf("failed to delete directory: %w", err)
@@ -123,19 +240,19 @@ func (s *Storage) DeleteDirectory(relativePath string, recursive bool) error {
 			return fmt.Errorf("failed to delete directory: %w", err)
 		}
 	}
-	
+
 	return nil
 }
 
 // CheckExists checks if a file or directory exists
 func (s *Storage) CheckExists(relativePath string) (bool, error) {
 	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
+
 	// Security check
 	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.config.StorageRoot)) {
 		return false, fmt.Errorf("invalid path: attempted path traversal")
 	}
-	
+
 	_, err := os.Stat(fullPath)
 	if err == nil {
 		return true, nil
@@ -149,34 +266,34 @@ func (s *Storage) CheckExists(relativePath string) (bool, error) {
 // GetFileInfo returns file information
 func (s *Storage) GetFileInfo(relativePath string) (os.FileInfo, error) {
 	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
+
 	// Security check
 	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.config.StorageRoot)) {
 		return nil, fmt.Errorf("invalid path: attempted path traversal")
 	}
-	
+
 	info, err := os.Stat(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
-	
+
 	return info, nil
 }
 
 // ListDirectory lists files and subdirectories in a directory
 func (s *Storage) ListDirectory(relativePath string) ([]os.FileInfo, error) {
 	fullPath := filepath.Join(s.config.StorageRoot, relativePath)
-	
+
 	// Security check
 	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.config.StorageRoot)) {
 		return nil, fmt.Errorf("invalid path: attempted path traversal")
 	}
-	
+
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list directory: %w", err)
 	}
-	
+
 	infos := make([]os.FileInfo, 0, len(entries))
 	for _, entry := range entries {
 		info, err := entry.Info()
@@ -185,6 +302,6 @@ func (s *Storage) ListDirectory(relativePath string) ([]os.FileInfo, error) {
 		}
 		infos = append(infos, info)
 	}
-	
+
 	return infos, nil
 }
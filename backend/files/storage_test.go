@@ -0,0 +1,219 @@
+package files
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	root := t.TempDir()
+	storage, err := NewStorage(Config{StorageRoot: root})
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	return storage
+}
+
+func TestUploadLifecycleWritesObject(t *testing.T) {
+	storage := newTestStorage(t)
+	content := []byte("hello content-addressable world")
+
+	uploadID, err := storage.InitUpload()
+	if err != nil {
+		t.Fatalf("InitUpload() error = %v", err)
+	}
+
+	offset, err := storage.WriteChunk(uploadID, 0, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("WriteChunk() error = %v", err)
+	}
+	if offset != int64(len(content)) {
+		t.Fatalf("WriteChunk() offset = %d, want %d", offset, len(content))
+	}
+
+	ref, err := storage.CompleteUpload(uploadID, "")
+	if err != nil {
+		t.Fatalf("CompleteUpload() error = %v", err)
+	}
+	if ref.AlreadyExisted {
+		t.Fatalf("CompleteUpload() AlreadyExisted = true on first upload")
+	}
+
+	reader, err := storage.OpenObject(ref.SHA256)
+	if err != nil {
+		t.Fatalf("OpenObject() error = %v", err)
+	}
+	defer reader.Close()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("OpenObject() content = %q, want %q", got, content)
+	}
+}
+
+func TestCompleteUploadRejectsHashMismatch(t *testing.T) {
+	storage := newTestStorage(t)
+
+	uploadID, err := storage.InitUpload()
+	if err != nil {
+		t.Fatalf("InitUpload() error = %v", err)
+	}
+	if _, err := storage.WriteChunk(uploadID, 0, bytes.NewReader([]byte("real content"))); err != nil {
+		t.Fatalf("WriteChunk() error = %v", err)
+	}
+
+	if _, err := storage.CompleteUpload(uploadID, "0000000000000000000000000000000000000000000000000000000000000000"); err == nil {
+		t.Fatal("CompleteUpload() expected error for sha256 mismatch, got nil")
+	}
+}
+
+func TestCompleteUploadDedupsIdenticalContent(t *testing.T) {
+	storage := newTestStorage(t)
+	content := []byte("duplicate me")
+
+	upload := func() *ObjectRef {
+		uploadID, err := storage.InitUpload()
+		if err != nil {
+			t.Fatalf("InitUpload() error = %v", err)
+		}
+		if _, err := storage.WriteChunk(uploadID, 0, bytes.NewReader(content)); err != nil {
+			t.Fatalf("WriteChunk() error = %v", err)
+		}
+		ref, err := storage.CompleteUpload(uploadID, "")
+		if err != nil {
+			t.Fatalf("CompleteUpload() error = %v", err)
+		}
+		return ref
+	}
+
+	first := upload()
+	if first.AlreadyExisted {
+		t.Fatal("first upload reported AlreadyExisted = true")
+	}
+
+	second := upload()
+	if !second.AlreadyExisted {
+		t.Fatal("second upload of identical content reported AlreadyExisted = false")
+	}
+	if second.SHA256 != first.SHA256 {
+		t.Fatalf("second.SHA256 = %s, want %s", second.SHA256, first.SHA256)
+	}
+}
+
+func TestWriteChunkSupportsResumedUpload(t *testing.T) {
+	storage := newTestStorage(t)
+
+	uploadID, err := storage.InitUpload()
+	if err != nil {
+		t.Fatalf("InitUpload() error = %v", err)
+	}
+
+	if _, err := storage.WriteChunk(uploadID, 0, bytes.NewReader([]byte("hello "))); err != nil {
+		t.Fatalf("WriteChunk() error = %v", err)
+	}
+
+	offset, err := storage.UploadOffset(uploadID)
+	if err != nil {
+		t.Fatalf("UploadOffset() error = %v", err)
+	}
+	if offset != 6 {
+		t.Fatalf("UploadOffset() = %d, want 6", offset)
+	}
+
+	if _, err := storage.WriteChunk(uploadID, offset, bytes.NewReader([]byte("world"))); err != nil {
+		t.Fatalf("WriteChunk() error = %v", err)
+	}
+
+	ref, err := storage.CompleteUpload(uploadID, "")
+	if err != nil {
+		t.Fatalf("CompleteUpload() error = %v", err)
+	}
+
+	reader, err := storage.OpenObject(ref.SHA256)
+	if err != nil {
+		t.Fatalf("OpenObject() error = %v", err)
+	}
+	defer reader.Close()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Fatalf("assembled content = %q, want %q", got, "hello world")
+	}
+}
+
+func TestRemoveObjectUnlinksBlob(t *testing.T) {
+	storage := newTestStorage(t)
+
+	uploadID, err := storage.InitUpload()
+	if err != nil {
+		t.Fatalf("InitUpload() error = %v", err)
+	}
+	if _, err := storage.WriteChunk(uploadID, 0, bytes.NewReader([]byte("gone soon"))); err != nil {
+		t.Fatalf("WriteChunk() error = %v", err)
+	}
+	ref, err := storage.CompleteUpload(uploadID, "")
+	if err != nil {
+		t.Fatalf("CompleteUpload() error = %v", err)
+	}
+
+	if err := storage.RemoveObject(ref.SHA256); err != nil {
+		t.Fatalf("RemoveObject() error = %v", err)
+	}
+
+	if _, err := storage.OpenObject(ref.SHA256); err == nil {
+		t.Fatal("OpenObject() expected error after RemoveObject(), got nil")
+	}
+
+	// Removing an already-gone object is a no-op, not an error.
+	if err := storage.RemoveObject(ref.SHA256); err != nil {
+		t.Fatalf("RemoveObject() on missing object error = %v, want nil", err)
+	}
+}
+
+func TestDirectoryOperationsRejectPathTraversal(t *testing.T) {
+	storage := newTestStorage(t)
+
+	if err := storage.CreateDirectory("../escape"); err == nil {
+		t.Fatal("CreateDirectory() expected path traversal error, got nil")
+	}
+	if _, err := storage.CheckExists("../escape"); err == nil {
+		t.Fatal("CheckExists() expected path traversal error, got nil")
+	}
+}
+
+func TestCreateDirectoryAndListDirectory(t *testing.T) {
+	storage := newTestStorage(t)
+
+	if err := storage.CreateDirectory("docs"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+
+	exists, err := storage.CheckExists("docs")
+	if err != nil {
+		t.Fatalf("CheckExists() error = %v", err)
+	}
+	if !exists {
+		t.Fatal("CheckExists() = false, want true after CreateDirectory")
+	}
+
+	if err := os.WriteFile(storage.config.StorageRoot+"/docs/note.txt", []byte("hi"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+
+	entries, err := storage.ListDirectory("docs")
+	if err != nil {
+		t.Fatalf("ListDirectory() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "note.txt" {
+		t.Fatalf("ListDirectory() = %v, want [note.txt]", entries)
+	}
+}
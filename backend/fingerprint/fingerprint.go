@@ -0,0 +1,52 @@
+// Package fingerprint computes content fingerprints for files, the way
+// tmsu's fingerprint module does: each algorithm is registered under a
+// name, the name is stored alongside the computed value, and new
+// algorithms can be added without touching anything that already has
+// fingerprints on disk.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// DefaultAlgorithm is used when a caller doesn't request one explicitly.
+const DefaultAlgorithm = "sha256"
+
+// Algorithm computes a fingerprint for the bytes read from r.
+type Algorithm interface {
+	Name() string
+	Compute(r io.Reader) (string, error)
+}
+
+var registry = map[string]Algorithm{}
+
+func init() {
+	Register(sha256Algorithm{})
+}
+
+// Register adds algo to the set available via Get. Registering a name
+// twice overwrites the previous registration.
+func Register(algo Algorithm) {
+	registry[algo.Name()] = algo
+}
+
+// Get looks up a registered algorithm by name.
+func Get(name string) (Algorithm, bool) {
+	algo, ok := registry[name]
+	return algo, ok
+}
+
+type sha256Algorithm struct{}
+
+func (sha256Algorithm) Name() string { return "sha256" }
+
+func (sha256Algorithm) Compute(r io.Reader) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", fmt.Errorf("failed to compute sha256 fingerprint: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
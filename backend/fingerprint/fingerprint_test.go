@@ -0,0 +1,71 @@
+package fingerprint
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDefaultAlgorithmIsRegistered(t *testing.T) {
+	algo, ok := Get(DefaultAlgorithm)
+	if !ok {
+		t.Fatalf("Get(%q) not found", DefaultAlgorithm)
+	}
+	if algo.Name() != DefaultAlgorithm {
+		t.Fatalf("algo.Name() = %q, want %q", algo.Name(), DefaultAlgorithm)
+	}
+}
+
+func TestSHA256ComputeIsDeterministic(t *testing.T) {
+	algo, _ := Get("sha256")
+
+	first, err := algo.Compute(strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	second, err := algo.Compute(strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if first != second {
+		t.Fatalf("Compute() = %q and %q, want equal", first, second)
+	}
+
+	different, err := algo.Compute(strings.NewReader("goodbye world"))
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if first == different {
+		t.Fatal("Compute() produced the same fingerprint for different content")
+	}
+}
+
+func TestGetUnknownAlgorithmReturnsFalse(t *testing.T) {
+	if _, ok := Get("does-not-exist"); ok {
+		t.Fatal("Get() of unregistered algorithm returned ok = true")
+	}
+}
+
+func TestRegisterAddsNewAlgorithm(t *testing.T) {
+	Register(stubAlgorithm{name: "stub-for-test"})
+	t.Cleanup(func() { delete(registry, "stub-for-test") })
+
+	algo, ok := Get("stub-for-test")
+	if !ok {
+		t.Fatal("Get() did not find newly registered algorithm")
+	}
+	got, err := algo.Compute(strings.NewReader("anything"))
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if got != "stub-fingerprint" {
+		t.Fatalf("Compute() = %q, want %q", got, "stub-fingerprint")
+	}
+}
+
+type stubAlgorithm struct{ name string }
+
+func (s stubAlgorithm) Name() string { return s.name }
+func (stubAlgorithm) Compute(r io.Reader) (string, error) {
+	return "stub-fingerprint", nil
+}
@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aleksandr/lifetrack/backend/auth"
+	"github.com/aleksandr/lifetrack/backend/graph/model"
+)
+
+// Auth Mutations
+
+// RefreshToken exchanges a refresh token for a new access token, rotating
+// the refresh token in the process: the caller must start using the
+// returned RefreshToken, since the one it passed in is now consumed.
+func (r *mutationResolver) RefreshToken(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	pair, err := r.Auth.RefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	return &model.TokenPair{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt,
+	}, nil
+}
+
+// Logout revokes a single refresh token, ending that session.
+func (r *mutationResolver) Logout(ctx context.Context, refreshToken string) (bool, error) {
+	if _, err := auth.GetUserFromContext(ctx); err != nil {
+		return false, fmt.Errorf("unauthorized")
+	}
+
+	if err := r.Auth.RevokeToken(ctx, refreshToken); err != nil {
+		return false, fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return true, nil
+}
+
+// LogoutAllSessions revokes every refresh token for the current user and
+// bumps their token version, so outstanding access tokens stop validating
+// immediately rather than once they expire.
+func (r *mutationResolver) LogoutAllSessions(ctx context.Context) (bool, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("unauthorized")
+	}
+
+	if err := r.Auth.RevokeAllForUser(ctx, currentUser.ID); err != nil {
+		return false, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return true, nil
+}
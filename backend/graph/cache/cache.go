@@ -0,0 +1,16 @@
+// Package cache provides the persisted-query cache used by
+// extension.AutomaticPersistedQuery, plus any other short-lived keyed state
+// (session lookups, rate-limit counters) that benefits from being shared
+// across replicas instead of living in process memory.
+package cache
+
+import "context"
+
+// Cache is the storage backend for automatic persisted queries. It mirrors
+// gqlgen's graphql.PersistedQueryCache shape (Add/Get with a string value)
+// so either implementation can be passed straight to
+// extension.AutomaticPersistedQuery.
+type Cache interface {
+	Add(ctx context.Context, key string, value string)
+	Get(ctx context.Context, key string) (string, bool)
+}
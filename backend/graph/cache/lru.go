@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// LRUConfig configures an in-process, bounded LRU cache.
+type LRUConfig struct {
+	MaxEntries int           // number of entries to retain; defaults to 1000
+	TTL        time.Duration // entry lifetime; zero means entries never expire
+}
+
+type lruEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// LRU is a bounded, optionally TTL'd in-memory Cache. Unlike a plain
+// map it evicts old entries instead of growing without bound, but it is
+// still per-process: entries are not shared across
+// replicas and are lost on restart.
+type LRU struct {
+	mu    sync.Mutex
+	cache *lru.Cache[string, lruEntry]
+	ttl   time.Duration
+}
+
+// NewLRU creates a bounded LRU cache per LRUConfig.
+func NewLRU(cfg LRUConfig) (*LRU, error) {
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+
+	c, err := lru.New[string, lruEntry](maxEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LRU{cache: c, ttl: cfg.TTL}, nil
+}
+
+func (l *LRU) Add(ctx context.Context, key string, value string) {
+	var expiresAt time.Time
+	if l.ttl > 0 {
+		expiresAt = time.Now().Add(l.ttl)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache.Add(key, lruEntry{value: value, expiresAt: expiresAt})
+}
+
+func (l *LRU) Get(ctx context.Context, key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.cache.Get(key)
+	if !ok {
+		return "", false
+	}
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		l.cache.Remove(key)
+		return "", false
+	}
+	return entry.value, true
+}
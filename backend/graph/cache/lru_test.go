@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUAddGet(t *testing.T) {
+	c, err := NewLRU(LRUConfig{MaxEntries: 10})
+	if err != nil {
+		t.Fatalf("NewLRU failed: %v", err)
+	}
+
+	ctx := context.Background()
+	c.Add(ctx, "key1", "value1")
+
+	value, ok := c.Get(ctx, "key1")
+	if !ok {
+		t.Fatal("Expected key1 to be present")
+	}
+	if value != "value1" {
+		t.Errorf("Expected value1, got %s", value)
+	}
+
+	if _, ok := c.Get(ctx, "missing"); ok {
+		t.Error("Expected missing key to be absent")
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	c, err := NewLRU(LRUConfig{MaxEntries: 2})
+	if err != nil {
+		t.Fatalf("NewLRU failed: %v", err)
+	}
+
+	ctx := context.Background()
+	c.Add(ctx, "a", "1")
+	c.Add(ctx, "b", "2")
+	c.Add(ctx, "c", "3") // evicts "a" (least recently used)
+
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Error("Expected oldest entry to be evicted")
+	}
+	if _, ok := c.Get(ctx, "c"); !ok {
+		t.Error("Expected most recently added entry to be present")
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c, err := NewLRU(LRUConfig{MaxEntries: 10, TTL: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewLRU failed: %v", err)
+	}
+
+	ctx := context.Background()
+	c.Add(ctx, "key1", "value1")
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get(ctx, "key1"); ok {
+		t.Error("Expected expired entry to be absent")
+	}
+}
+
+func TestRedisAddrFromURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "plain", url: "redis://localhost:6379", want: "localhost:6379"},
+		{name: "with auth", url: "redis://:password@redis:6379", want: "redis:6379"},
+		{name: "with db", url: "redis://localhost:6379/1", want: "localhost:6379"},
+		{name: "tls scheme", url: "rediss://localhost:6380", want: "localhost:6380"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedisAddrFromURL(tt.url); got != tt.want {
+				t.Errorf("RedisAddrFromURL(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keyPrefix namespaces every key this package writes so the APQ cache can
+// share a Redis/Valkey instance with other consumers.
+const keyPrefix = "apq:"
+
+// Redis is a Cache backed by a Redis or Valkey server, reached over a small
+// hand-rolled RESP client so the persisted-query cache doesn't pull in a
+// full client library for two commands (SET ... EX and GET).
+type Redis struct {
+	addr string
+	ttl  time.Duration
+}
+
+// NewRedis creates a Redis-backed Cache. addr is a "host:port" pair; use
+// RedisAddrFromURL to derive it from a redis://... connection string.
+func NewRedis(addr string, ttl time.Duration) *Redis {
+	return &Redis{addr: addr, ttl: ttl}
+}
+
+// RedisAddrFromURL extracts the host:port from a redis://[:password@]host:port[/db]
+// URL. Auth and DB selection are not needed for the APQ use case today.
+func RedisAddrFromURL(url string) string {
+	addr := strings.TrimPrefix(url, "redis://")
+	addr = strings.TrimPrefix(addr, "rediss://")
+	if i := strings.Index(addr, "@"); i != -1 {
+		addr = addr[i+1:]
+	}
+	if i := strings.Index(addr, "/"); i != -1 {
+		addr = addr[:i]
+	}
+	return addr
+}
+
+func (r *Redis) Add(ctx context.Context, key string, value string) {
+	ttlSeconds := int(r.ttl.Seconds())
+	if ttlSeconds <= 0 {
+		ttlSeconds = 1
+	}
+
+	if _, err := r.do(ctx, "SET", keyPrefix+key, value, "EX", strconv.Itoa(ttlSeconds)); err != nil {
+		log.Printf("cache: redis SET failed: %v", err)
+	}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (string, bool) {
+	reply, err := r.do(ctx, "GET", keyPrefix+key)
+	if err != nil || reply == nil {
+		return "", false
+	}
+	return *reply, true
+}
+
+// do issues a single RESP command and returns a bulk-string reply, or nil if
+// the server replied with a null bulk string (key miss).
+func (r *Redis) do(ctx context.Context, args ...string) (*string, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(2 * time.Second)
+	}
+
+	conn, err := net.DialTimeout("tcp", r.addr, time.Until(deadline))
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to connect to redis: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(encodeCommand(args)); err != nil {
+		return nil, fmt.Errorf("cache: failed to write redis command: %w", err)
+	}
+
+	return readBulkReply(bufio.NewReader(conn))
+}
+
+// encodeCommand renders args as a RESP array of bulk strings.
+func encodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// readBulkReply reads a single RESP reply and extracts it as a bulk string.
+// Simple status (+OK) and error (-ERR) replies are handled too, since SET
+// (with or without EX) replies +OK rather than with a bulk string.
+func readBulkReply(r *bufio.Reader) (*string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to read redis reply: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("cache: empty redis reply")
+	}
+
+	switch line[0] {
+	case '+': // simple string, e.g. +OK
+		value := line[1:]
+		return &value, nil
+	case '-': // error
+		return nil, fmt.Errorf("cache: redis error: %s", line[1:])
+	case '$': // bulk string
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("cache: malformed bulk length: %w", err)
+		}
+		if n < 0 {
+			return nil, nil // null bulk string: key miss
+		}
+		buf := make([]byte, n+2) // payload + trailing CRLF
+		if _, err := readFull(r, buf); err != nil {
+			return nil, fmt.Errorf("cache: failed to read bulk payload: %w", err)
+		}
+		value := string(buf[:n])
+		return &value, nil
+	default:
+		return nil, fmt.Errorf("cache: unexpected redis reply type %q", line[0])
+	}
+}
+
+func readFull(r *bufio.Reader, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -4,22 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aleksandr/lifetrack/backend/auth"
+	"github.com/aleksandr/lifetrack/backend/changefeed"
+	"github.com/aleksandr/lifetrack/backend/fingerprint"
 	"github.com/aleksandr/lifetrack/backend/graph/model"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
+// streamURLTTL is how long a signed /files/stream/ URL stays valid.
+const streamURLTTL = 15 * time.Minute
+
 // File Queries
 
 // Files returns a paginated list of files for the current user
 func (r *queryResolver) Files(ctx context.Context, filter *model.FileFilter, limit *int, offset *int) (*model.FileConnection, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "files:read")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
+		return nil, err
 	}
 
 	limitVal := 50
@@ -32,7 +40,7 @@ func (r *queryResolver) Files(ctx context.Context, filter *model.FileFilter, lim
 	}
 
 	// Build query with filters
-	baseQuery := `FROM files WHERE user_id = $1`
+	baseQuery := `FROM files WHERE user_id = $1 AND deleted_at IS NULL`
 	args := []interface{}{currentUser.ID}
 	argCount := 1
 
@@ -57,6 +65,11 @@ func (r *queryResolver) Files(ctx context.Context, filter *model.FileFilter, lim
 			baseQuery += fmt.Sprintf(" AND tags && $%d", argCount)
 			args = append(args, pq.Array(filter.Tags))
 		}
+		if filter.Fingerprint != nil {
+			argCount++
+			baseQuery += fmt.Sprintf(" AND fingerprint = $%d", argCount)
+			args = append(args, *filter.Fingerprint)
+		}
 	}
 
 	// Count total
@@ -73,7 +86,7 @@ func (r *queryResolver) Files(ctx context.Context, filter *model.FileFilter, lim
 	// Build full query for fetching files
 	query := `SELECT id, user_id, filename, directory, original_filename, mime_type, 
 	          file_size, telegram_file_id, telegram_file_unique_id, telegram_message_id,
-	          storage_path, tags, description, created_at, updated_at ` + baseQuery
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at ` + baseQuery
 	query += " ORDER BY created_at DESC"
 	argCount++
 	query += fmt.Sprintf(" LIMIT $%d", argCount)
@@ -108,15 +121,15 @@ func (r *queryResolver) Files(ctx context.Context, filter *model.FileFilter, lim
 
 // File returns a single file by ID
 func (r *queryResolver) File(ctx context.Context, id uuid.UUID) (*model.File, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "files:read")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
+		return nil, err
 	}
 
-	query := `SELECT id, user_id, filename, directory, original_filename, mime_type, 
+	query := `SELECT id, user_id, filename, directory, original_filename, mime_type,
 	          file_size, telegram_file_id, telegram_file_unique_id, telegram_message_id,
-	          storage_path, tags, description, created_at, updated_at
-	          FROM files WHERE id = $1 AND user_id = $2`
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at
+	          FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 
 	file, err := scanFile(r.DB.QueryRowContext(ctx, query, id, currentUser.ID))
 	if err == sql.ErrNoRows {
@@ -129,11 +142,114 @@ func (r *queryResolver) File(ctx context.Context, id uuid.UUID) (*model.File, er
 	return file, nil
 }
 
+// StreamURL issues a short-lived, signed URL for GET /files/stream/{id},
+// which serves the file's bytes with HTTP range support without requiring
+// an Authorization header (so it can be handed to a <video>/<audio> tag).
+func (r *queryResolver) StreamURL(ctx context.Context, id uuid.UUID) (string, error) {
+	currentUser, err := auth.RequireScope(ctx, "files:read")
+	if err != nil {
+		return "", err
+	}
+
+	var exists bool
+	err = r.DB.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)",
+		id, currentUser.ID,
+	).Scan(&exists)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up file: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("file not found")
+	}
+
+	fileID := id.String()
+	expires, sig := r.StreamURLSigner.Sign(fileID, time.Now().Add(streamURLTTL))
+	return fmt.Sprintf("/files/stream/%s?expires=%s&sig=%s", fileID, expires, sig), nil
+}
+
+// FilesByFingerprint returns every file owned by the current user whose
+// recorded fingerprint matches the given algorithm/value pair, regardless
+// of directory. Useful for finding duplicates before calling DedupeFiles.
+func (r *queryResolver) FilesByFingerprint(ctx context.Context, algorithm string, fingerprintValue string) ([]*model.File, error) {
+	currentUser, err := auth.RequireScope(ctx, "files:read")
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, user_id, filename, directory, original_filename, mime_type,
+	          file_size, telegram_file_id, telegram_file_unique_id, telegram_message_id,
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at
+	          FROM files WHERE user_id = $1 AND fingerprint_algorithm = $2 AND fingerprint = $3 AND deleted_at IS NULL
+	          ORDER BY created_at DESC`
+
+	rows, err := r.DB.QueryContext(ctx, query, currentUser.ID, algorithm, fingerprintValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files by fingerprint: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*model.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// TrashedFiles returns the current user's trashed files, optionally
+// limited to those trashed at least retentionDays ago — useful for
+// previewing what PurgeTrash (or the background sweeper, once its TTL
+// elapses) would remove.
+func (r *queryResolver) TrashedFiles(ctx context.Context, retentionDays *int) ([]*model.File, error) {
+	currentUser, err := auth.RequireScope(ctx, "files:read")
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, user_id, filename, directory, original_filename, mime_type,
+	          file_size, telegram_file_id, telegram_file_unique_id, telegram_message_id,
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at
+	          FROM files WHERE user_id = $1 AND deleted_at IS NOT NULL`
+	args := []interface{}{currentUser.ID}
+
+	if retentionDays != nil {
+		query += " AND deleted_at <= NOW() - ($2 || ' days')::interval"
+		args = append(args, *retentionDays)
+	}
+	query += " ORDER BY deleted_at DESC"
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trashed files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*model.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
 // Directories returns a list of directories
+// Directories returns the immediate children of parentPath. Each child's
+// subdirectory names and recursive file-count/size rollups are computed by a
+// single CTE over the materialized-path depth column instead of the
+// previous one-query-per-discovered-directory scan.
 func (r *queryResolver) Directories(ctx context.Context, parentPath *string) ([]*model.Directory, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "directories:read")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
+		return nil, err
 	}
 
 	parent := "/"
@@ -141,198 +257,135 @@ func (r *queryResolver) Directories(ctx context.Context, parentPath *string) ([]
 		parent = *parentPath
 	}
 
-	// Collect all directories from both files and directories table
-	dirMap := make(map[string]bool)
-	
-	// Get directories from files table
-	filesQuery := `SELECT DISTINCT directory FROM files 
-	               WHERE user_id = $1 AND directory LIKE $2 
-	               ORDER BY directory`
-
 	pattern := parent
 	if !strings.HasSuffix(pattern, "/") {
 		pattern += "/"
 	}
 	pattern += "%"
 
-	rows, err := r.DB.QueryContext(ctx, filesQuery, currentUser.ID, pattern)
+	query := `
+		WITH children AS (
+			SELECT path, depth FROM directories
+			WHERE user_id = $1 AND deleted_at IS NULL AND path LIKE $2 AND depth = $3
+		)
+		SELECT
+			c.path,
+			c.depth,
+			COALESCE(sub.names, '{}'),
+			COALESCE(agg.file_count, 0),
+			COALESCE(agg.recursive_file_count, 0),
+			COALESCE(agg.total_size, 0)
+		FROM children c
+		LEFT JOIN LATERAL (
+			SELECT array_agg(substring(d2.path FROM char_length(c.path) + 2)) AS names
+			FROM directories d2
+			WHERE d2.user_id = $1 AND d2.deleted_at IS NULL
+			  AND d2.path LIKE c.path || '/%' AND d2.depth = c.depth + 1
+		) sub ON true
+		LEFT JOIN LATERAL (
+			SELECT
+				COUNT(*) FILTER (WHERE directory = c.path) AS file_count,
+				COUNT(*) AS recursive_file_count,
+				COALESCE(SUM(file_size), 0) AS total_size
+			FROM files
+			WHERE user_id = $1 AND deleted_at IS NULL
+			  AND (directory = c.path OR directory LIKE c.path || '/%')
+		) agg ON true
+		ORDER BY c.path`
+
+	rows, err := r.DB.QueryContext(ctx, query, currentUser.ID, pattern, directoryDepth(parent)+1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query file directories: %w", err)
+		return nil, fmt.Errorf("failed to query directories: %w", err)
 	}
 	defer rows.Close()
 
+	directories := []*model.Directory{}
 	for rows.Next() {
-		var dir string
-		if err := rows.Scan(&dir); err != nil {
-			return nil, err
-		}
-		// Extract immediate subdirectory
-		rel := strings.TrimPrefix(dir, parent)
-		rel = strings.TrimPrefix(rel, "/")
-		parts := strings.Split(rel, "/")
-		if len(parts) > 0 && parts[0] != "" {
-			subdir := filepath.Join(parent, parts[0])
-			dirMap[subdir] = true
-		}
-	}
-	
-	// Get directories from directories table
-	dirsQuery := `SELECT path FROM directories 
-	              WHERE user_id = $1 AND path LIKE $2 
-	              ORDER BY path`
-	
-	dirRows, err := r.DB.QueryContext(ctx, dirsQuery, currentUser.ID, pattern)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query directories table: %w", err)
-	}
-	defer dirRows.Close()
-	
-	for dirRows.Next() {
-		var dir string
-		if err := dirRows.Scan(&dir); err != nil {
-			return nil, err
-		}
-		// Extract immediate subdirectory
-		rel := strings.TrimPrefix(dir, parent)
-		rel = strings.TrimPrefix(rel, "/")
-		parts := strings.Split(rel, "/")
-		if len(parts) > 0 && parts[0] != "" {
-			subdir := filepath.Join(parent, parts[0])
-			dirMap[subdir] = true
-		}
-	}
+		var path string
+		var depth, fileCount, recursiveFileCount int
+		var totalSize int64
+		var subdirs pq.StringArray
 
-	directories := []*model.Directory{}
-	for dir := range dirMap {
-		// Count files in this directory
-		countQuery := `SELECT COUNT(*) FROM files WHERE user_id = $1 AND directory = $2`
-		var fileCount int
-		err := r.DB.QueryRowContext(ctx, countQuery, currentUser.ID, dir).Scan(&fileCount)
-		if err != nil {
-			fileCount = 0
-		}
-
-		// Get subdirectories (from both sources)
-		subDirMap := make(map[string]bool)
-		
-		// From files
-		subFilesQuery := `SELECT DISTINCT directory FROM files WHERE user_id = $1 AND directory LIKE $2`
-		subPattern := dir
-		if !strings.HasSuffix(subPattern, "/") {
-			subPattern += "/"
-		}
-		subPattern += "%"
-
-		subRows, err := r.DB.QueryContext(ctx, subFilesQuery, currentUser.ID, subPattern)
-		if err == nil {
-			defer subRows.Close()
-			for subRows.Next() {
-				var subDir string
-				if err := subRows.Scan(&subDir); err == nil {
-					rel := strings.TrimPrefix(subDir, dir)
-					rel = strings.TrimPrefix(rel, "/")
-					parts := strings.Split(rel, "/")
-					if len(parts) > 0 && parts[0] != "" {
-						subDirMap[parts[0]] = true
-					}
-				}
-			}
-		}
-		
-		// From directories table
-		subDirsQuery := `SELECT path FROM directories WHERE user_id = $1 AND path LIKE $2`
-		subDirRows, err := r.DB.QueryContext(ctx, subDirsQuery, currentUser.ID, subPattern)
-		if err == nil {
-			defer subDirRows.Close()
-			for subDirRows.Next() {
-				var subDir string
-				if err := subDirRows.Scan(&subDir); err == nil {
-					rel := strings.TrimPrefix(subDir, dir)
-					rel = strings.TrimPrefix(rel, "/")
-					parts := strings.Split(rel, "/")
-					if len(parts) > 0 && parts[0] != "" {
-						subDirMap[parts[0]] = true
-					}
-				}
-			}
-		}
-		
-		subdirs := []string{}
-		for s := range subDirMap {
-			subdirs = append(subdirs, s)
+		if err := rows.Scan(&path, &depth, &subdirs, &fileCount, &recursiveFileCount, &totalSize); err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
 		}
 
 		parentDir := parent
-		if dir == "/" {
-			parentDir = ""
-		}
-
 		directories = append(directories, &model.Directory{
-			Path:            dir,
-			Parent:          &parentDir,
-			Subdirectories:  subdirs,
-			FileCount:       fileCount,
+			Path:               path,
+			Parent:             &parentDir,
+			Subdirectories:     []string(subdirs),
+			FileCount:          fileCount,
+			RecursiveFileCount: recursiveFileCount,
+			TotalSize:          totalSize,
+			Depth:              depth,
 		})
 	}
 
 	return directories, nil
 }
 
-// Directory returns information about a specific directory
+// Directory returns information about a specific directory, including its
+// immediate subdirectories and recursive file-count/size rollups.
 func (r *queryResolver) Directory(ctx context.Context, path string) (*model.Directory, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "directories:read")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
-	}
-
-	// Count files in this directory
-	countQuery := `SELECT COUNT(*) FROM files WHERE user_id = $1 AND directory = $2`
-	var fileCount int
-	err = r.DB.QueryRowContext(ctx, countQuery, currentUser.ID, path).Scan(&fileCount)
-	if err != nil {
-		fileCount = 0
+		return nil, err
 	}
+	return directoryByPath(ctx, r.Resolver, currentUser, path)
+}
 
-	// Get subdirectories
-	subQuery := `SELECT DISTINCT directory FROM files WHERE user_id = $1 AND directory LIKE $2`
+// directoryByPath is the shared implementation behind the Directory query
+// resolver. It also backs CreateDirectory/MoveDirectory, which already
+// required directories:write for the caller and so build their response
+// from the already-authorized user rather than re-checking directories:read.
+func directoryByPath(ctx context.Context, r *Resolver, currentUser *auth.User, path string) (*model.Directory, error) {
 	pattern := path
 	if !strings.HasSuffix(pattern, "/") {
 		pattern += "/"
 	}
 	pattern += "%"
+	depth := directoryDepth(path)
+
+	var subdirs pq.StringArray
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(array_agg(substring(path FROM char_length($2) + 2)), '{}')
+		FROM directories
+		WHERE user_id = $1 AND deleted_at IS NULL AND path LIKE $3 AND depth = $4`,
+		currentUser.ID, path, pattern, depth+1,
+	).Scan(&subdirs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subdirectories: %w", err)
+	}
 
-	rows, err := r.DB.QueryContext(ctx, subQuery, currentUser.ID, pattern)
-	subdirs := []string{}
-	if err == nil {
-		defer rows.Close()
-		subDirMap := make(map[string]bool)
-		for rows.Next() {
-			var dir string
-			if err := rows.Scan(&dir); err == nil {
-				rel := strings.TrimPrefix(dir, path)
-				rel = strings.TrimPrefix(rel, "/")
-				parts := strings.Split(rel, "/")
-				if len(parts) > 0 && parts[0] != "" {
-					subDirMap[parts[0]] = true
-				}
-			}
-		}
-		for s := range subDirMap {
-			subdirs = append(subdirs, s)
-		}
+	var fileCount, recursiveFileCount int
+	var totalSize int64
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE directory = $2),
+			COUNT(*),
+			COALESCE(SUM(file_size), 0)
+		FROM files
+		WHERE user_id = $1 AND deleted_at IS NULL AND (directory = $2 OR directory LIKE $3)`,
+		currentUser.ID, path, pattern,
+	).Scan(&fileCount, &recursiveFileCount, &totalSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query directory file stats: %w", err)
 	}
 
-	// Get parent directory
 	parentDir := "/"
 	if path != "/" {
 		parentDir = filepath.Dir(path)
 	}
 
 	return &model.Directory{
-		Path:            path,
-		Parent:          &parentDir,
-		Subdirectories:  subdirs,
-		FileCount:       fileCount,
+		Path:               path,
+		Parent:             &parentDir,
+		Subdirectories:     []string(subdirs),
+		FileCount:          fileCount,
+		RecursiveFileCount: recursiveFileCount,
+		TotalSize:          totalSize,
+		Depth:              depth,
 	}, nil
 }
 
@@ -340,18 +393,31 @@ func (r *queryResolver) Directory(ctx context.Context, path string) (*model.Dire
 
 // CreateFile creates a new file record
 func (r *mutationResolver) CreateFile(ctx context.Context, input model.CreateFileInput) (*model.File, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "files:write")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
+		return nil, err
 	}
 
-	query := `INSERT INTO files 
+	algorithmName := ""
+	if input.FingerprintAlgorithm != nil {
+		algorithmName = *input.FingerprintAlgorithm
+	}
+	fpAlgorithm, fpValue, err := computeFingerprint(ctx, r.Resolver, algorithmName, input.StoragePath, input.TelegramFileID, input.FileSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute file fingerprint: %w", err)
+	}
+	if input.Fingerprint != nil && *input.Fingerprint != fpValue {
+		return nil, fmt.Errorf("declared fingerprint does not match the file's computed fingerprint")
+	}
+
+	query := `INSERT INTO files
 	          (user_id, filename, directory, original_filename, mime_type, file_size,
-	           telegram_file_id, telegram_file_unique_id, telegram_message_id, storage_path, tags, description)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	          RETURNING id, user_id, filename, directory, original_filename, mime_type, 
+	           telegram_file_id, telegram_file_unique_id, telegram_message_id, storage_path, tags, description,
+	           fingerprint_algorithm, fingerprint)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	          RETURNING id, user_id, filename, directory, original_filename, mime_type,
 	          file_size, telegram_file_id, telegram_file_unique_id, telegram_message_id,
-	          storage_path, tags, description, created_at, updated_at`
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at`
 
 	tags := []string{}
 	if input.Tags != nil {
@@ -371,20 +437,26 @@ func (r *mutationResolver) CreateFile(ctx context.Context, input model.CreateFil
 		input.StoragePath,
 		pq.Array(tags),
 		input.Description,
+		fpAlgorithm,
+		fpValue,
 	))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
 
+	if _, err := r.Changes.Publish(ctx, currentUser.ID, changefeed.EntityFile, changefeed.OperationCreate, file.ID.String(), file.Directory, file); err != nil {
+		return nil, fmt.Errorf("failed to publish change-feed event: %w", err)
+	}
+
 	return file, nil
 }
 
 // UpdateFile updates file metadata
 func (r *mutationResolver) UpdateFile(ctx context.Context, id uuid.UUID, input model.UpdateFileInput) (*model.File, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "files:write")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
+		return nil, err
 	}
 
 	// Build dynamic update query
@@ -416,6 +488,29 @@ func (r *mutationResolver) UpdateFile(ctx context.Context, id uuid.UUID, input m
 		argCount++
 		updates = append(updates, fmt.Sprintf("telegram_file_id = $%d", argCount))
 		args = append(args, *input.TelegramFileID)
+
+		// The telegram copy is the file's content, so swapping it means the
+		// previously recorded fingerprint no longer applies; recompute it.
+		var fileSize int64
+		var algorithmName string
+		if err := r.DB.QueryRowContext(ctx,
+			"SELECT file_size, COALESCE(fingerprint_algorithm, '') FROM files WHERE id = $1 AND user_id = $2",
+			id, currentUser.ID,
+		).Scan(&fileSize, &algorithmName); err != nil {
+			return nil, fmt.Errorf("failed to look up file: %w", err)
+		}
+
+		fpAlgorithm, fpValue, err := computeFingerprint(ctx, r.Resolver, algorithmName, "", input.TelegramFileID, fileSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recompute file fingerprint: %w", err)
+		}
+
+		argCount++
+		updates = append(updates, fmt.Sprintf("fingerprint_algorithm = $%d", argCount))
+		args = append(args, fpAlgorithm)
+		argCount++
+		updates = append(updates, fmt.Sprintf("fingerprint = $%d", argCount))
+		args = append(args, fpValue)
 	}
 
 	if len(updates) == 0 {
@@ -427,7 +522,7 @@ func (r *mutationResolver) UpdateFile(ctx context.Context, id uuid.UUID, input m
 	query := fmt.Sprintf(`UPDATE files SET %s WHERE id = $1 AND user_id = $2
 	                      RETURNING id, user_id, filename, directory, original_filename, mime_type, 
 	                      file_size, telegram_file_id, telegram_file_unique_id, telegram_message_id,
-	                      storage_path, tags, description, created_at, updated_at`,
+	                      storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at`,
 		strings.Join(updates, ", "))
 
 	file, err := scanFile(r.DB.QueryRowContext(ctx, query, args...))
@@ -438,69 +533,78 @@ func (r *mutationResolver) UpdateFile(ctx context.Context, id uuid.UUID, input m
 		return nil, fmt.Errorf("failed to update file: %w", err)
 	}
 
+	if _, err := r.Changes.Publish(ctx, currentUser.ID, changefeed.EntityFile, changefeed.OperationUpdate, file.ID.String(), file.Directory, file); err != nil {
+		return nil, fmt.Errorf("failed to publish change-feed event: %w", err)
+	}
+
 	return file, nil
 }
 
-// DeleteFile deletes a file record
+// DeleteFile moves a file to the trash. It's recoverable via RestoreFile
+// until the background sweeper (see the trash package) hard-deletes it
+// once past the retention period; removeFromStorage only records whether
+// that eventual purge should also release the blob from file_objects, it
+// does not touch storage immediately.
 func (r *mutationResolver) DeleteFile(ctx context.Context, id uuid.UUID, removeFromStorage *bool) (bool, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "files:delete")
 	if err != nil {
-		return false, fmt.Errorf("unauthorized")
+		return false, err
 	}
 
-	// Get file info first if we need to delete from storage
-	if removeFromStorage != nil && *removeFromStorage {
-		var storagePath string
-		err := r.DB.QueryRowContext(ctx,
-			"SELECT storage_path FROM files WHERE id = $1 AND user_id = $2",
-			id, currentUser.ID).Scan(&storagePath)
-		if err != nil {
-			return false, fmt.Errorf("file not found")
-		}
+	purgeStorage := removeFromStorage != nil && *removeFromStorage
 
-		// Delete from storage (you'll need to implement this with your Storage instance)
-		// For now, we'll just delete the DB record
+	var directory string
+	err = r.DB.QueryRowContext(ctx, `
+		UPDATE files SET deleted_at = NOW(), purge_storage = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+		RETURNING directory`,
+		purgeStorage, id, currentUser.ID,
+	).Scan(&directory)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-
-	query := `DELETE FROM files WHERE id = $1 AND user_id = $2`
-	result, err := r.DB.ExecContext(ctx, query, id, currentUser.ID)
 	if err != nil {
-		return false, fmt.Errorf("failed to delete file: %w", err)
+		return false, fmt.Errorf("failed to trash file: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return false, fmt.Errorf("failed to check deletion: %w", err)
+	if _, err := r.Changes.Publish(ctx, currentUser.ID, changefeed.EntityFile, changefeed.OperationDelete, id.String(), directory, &model.File{ID: id, Directory: directory}); err != nil {
+		return false, fmt.Errorf("failed to publish change-feed event: %w", err)
 	}
 
-	return rows > 0, nil
+	return true, nil
 }
 
 // CreateDirectory creates a new directory
 func (r *mutationResolver) CreateDirectory(ctx context.Context, path string) (*model.Directory, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "directories:write")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
+		return nil, err
 	}
 
-	// Insert directory into directories table
-	query := `INSERT INTO directories (user_id, path) VALUES ($1, $2) 
-	          ON CONFLICT (user_id, path) DO NOTHING`
-	_, err = r.DB.ExecContext(ctx, query, currentUser.ID, path)
-	if err != nil {
+	if err := ensureDirectoryPath(ctx, r.DB, currentUser.ID, path); err != nil {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
 	// Return the directory structure
-	qr := queryResolver{r.Resolver}
-	return qr.Directory(ctx, path)
+	directory, err := directoryByPath(ctx, r.Resolver, currentUser, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Changes.Publish(ctx, currentUser.ID, changefeed.EntityDirectory, changefeed.OperationCreate, "", directory.Path, directory); err != nil {
+		return nil, fmt.Errorf("failed to publish change-feed event: %w", err)
+	}
+
+	return directory, nil
 }
 
-// DeleteDirectory deletes a directory
+// DeleteDirectory moves a directory to the trash, recoverable via
+// RestoreDirectory until the background sweeper hard-deletes it past the
+// retention period.
 func (r *mutationResolver) DeleteDirectory(ctx context.Context, path string, recursive *bool) (bool, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "directories:delete")
 	if err != nil {
-		return false, fmt.Errorf("unauthorized")
+		return false, err
 	}
 
 	isRecursive := false
@@ -509,28 +613,30 @@ func (r *mutationResolver) DeleteDirectory(ctx context.Context, path string, rec
 	}
 
 	if isRecursive {
-		// Delete all files in directory and subdirectories
+		// Trash all files in the directory and its subdirectories
 		pattern := path
 		if !strings.HasSuffix(pattern, "/") {
 			pattern += "/"
 		}
 		pattern += "%"
 
-		query := `DELETE FROM files WHERE user_id = $1 AND (directory = $2 OR directory LIKE $3)`
+		query := `UPDATE files SET deleted_at = NOW()
+		          WHERE user_id = $1 AND (directory = $2 OR directory LIKE $3) AND deleted_at IS NULL`
 		_, err := r.DB.ExecContext(ctx, query, currentUser.ID, path, pattern)
 		if err != nil {
-			return false, fmt.Errorf("failed to delete directory files: %w", err)
+			return false, fmt.Errorf("failed to trash directory files: %w", err)
 		}
-		
-		// Delete directory entries
-		dirQuery := `DELETE FROM directories WHERE user_id = $1 AND (path = $2 OR path LIKE $3)`
+
+		// Trash directory entries
+		dirQuery := `UPDATE directories SET deleted_at = NOW()
+		             WHERE user_id = $1 AND (path = $2 OR path LIKE $3) AND deleted_at IS NULL`
 		_, err = r.DB.ExecContext(ctx, dirQuery, currentUser.ID, path, pattern)
 		if err != nil {
-			return false, fmt.Errorf("failed to delete directory entries: %w", err)
+			return false, fmt.Errorf("failed to trash directory entries: %w", err)
 		}
 	} else {
-		// Only delete if empty (no files and no subdirectories)
-		countQuery := `SELECT COUNT(*) FROM files WHERE user_id = $1 AND directory = $2`
+		// Only trash if empty (no files and no subdirectories)
+		countQuery := `SELECT COUNT(*) FROM files WHERE user_id = $1 AND directory = $2 AND deleted_at IS NULL`
 		var count int
 		err := r.DB.QueryRowContext(ctx, countQuery, currentUser.ID, path).Scan(&count)
 		if err != nil {
@@ -540,33 +646,37 @@ func (r *mutationResolver) DeleteDirectory(ctx context.Context, path string, rec
 		if count > 0 {
 			return false, fmt.Errorf("directory not empty")
 		}
-		
+
 		// Check for subdirectories
 		pattern := path
 		if !strings.HasSuffix(pattern, "/") {
 			pattern += "/"
 		}
 		pattern += "%"
-		
+
 		var subDirCount int
-		subDirQuery := `SELECT COUNT(*) FROM directories WHERE user_id = $1 AND path LIKE $2 AND path != $3`
+		subDirQuery := `SELECT COUNT(*) FROM directories WHERE user_id = $1 AND path LIKE $2 AND path != $3 AND deleted_at IS NULL`
 		err = r.DB.QueryRowContext(ctx, subDirQuery, currentUser.ID, pattern, path).Scan(&subDirCount)
 		if err != nil {
 			return false, fmt.Errorf("failed to check subdirectories: %w", err)
 		}
-		
+
 		if subDirCount > 0 {
 			return false, fmt.Errorf("directory has subdirectories")
 		}
-		
-		// Delete empty directory
-		dirQuery := `DELETE FROM directories WHERE user_id = $1 AND path = $2`
+
+		// Trash the now-empty directory entry
+		dirQuery := `UPDATE directories SET deleted_at = NOW() WHERE user_id = $1 AND path = $2 AND deleted_at IS NULL`
 		_, err = r.DB.ExecContext(ctx, dirQuery, currentUser.ID, path)
 		if err != nil {
-			return false, fmt.Errorf("failed to delete directory entry: %w", err)
+			return false, fmt.Errorf("failed to trash directory entry: %w", err)
 		}
 	}
 
+	if _, err := r.Changes.Publish(ctx, currentUser.ID, changefeed.EntityDirectory, changefeed.OperationDelete, "", path, &model.Directory{Path: path}); err != nil {
+		return false, fmt.Errorf("failed to publish change-feed event: %w", err)
+	}
+
 	return true, nil
 }
 
@@ -574,16 +684,20 @@ func (r *mutationResolver) DeleteDirectory(ctx context.Context, path string, rec
 // Note: This only changes the directory field in the database (virtual/logical path).
 // The physical file location (storage_path) remains unchanged.
 func (r *mutationResolver) MoveFile(ctx context.Context, id uuid.UUID, newDirectory string) (*model.File, error) {
-	currentUser, err := auth.GetUserFromContext(ctx)
+	currentUser, err := auth.RequireScope(ctx, "files:write")
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized")
+		return nil, err
+	}
+
+	if err := ensureDirectoryPath(ctx, r.DB, currentUser.ID, newDirectory); err != nil {
+		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
-	query := `UPDATE files SET directory = $1, updated_at = NOW() 
+	query := `UPDATE files SET directory = $1, updated_at = NOW()
 	          WHERE id = $2 AND user_id = $3
-	          RETURNING id, user_id, filename, directory, original_filename, mime_type, 
+	          RETURNING id, user_id, filename, directory, original_filename, mime_type,
 	          file_size, telegram_file_id, telegram_file_unique_id, telegram_message_id,
-	          storage_path, tags, description, created_at, updated_at`
+	          storage_path, tags, description, fingerprint_algorithm, fingerprint, created_at, updated_at`
 
 	file, err := scanFile(r.DB.QueryRowContext(ctx, query, newDirectory, id, currentUser.ID))
 	if err == sql.ErrNoRows {
@@ -593,14 +707,402 @@ func (r *mutationResolver) MoveFile(ctx context.Context, id uuid.UUID, newDirect
 		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
+	if _, err := r.Changes.Publish(ctx, currentUser.ID, changefeed.EntityFile, changefeed.OperationMove, file.ID.String(), file.Directory, file); err != nil {
+		return nil, fmt.Errorf("failed to publish change-feed event: %w", err)
+	}
+
 	return file, nil
 }
 
+// MoveDirectory renames path to newPath, atomically rewriting the path and
+// depth of every descendant directory and file in a single transaction.
+func (r *mutationResolver) MoveDirectory(ctx context.Context, path string, newPath string) (*model.Directory, error) {
+	currentUser, err := auth.RequireScope(ctx, "directories:write")
+	if err != nil {
+		return nil, err
+	}
+	if path == "/" {
+		return nil, fmt.Errorf("cannot move the root directory")
+	}
+
+	pattern := path
+	if !strings.HasSuffix(pattern, "/") {
+		pattern += "/"
+	}
+	pattern += "%"
+	depthDelta := directoryDepth(newPath) - directoryDepth(path)
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE directories
+		SET path = $1 || substring(path FROM char_length($2) + 1), depth = depth + $3
+		WHERE user_id = $4 AND deleted_at IS NULL AND (path = $2 OR path LIKE $5)`,
+		newPath, path, depthDelta, currentUser.ID, pattern,
+	); err != nil {
+		return nil, fmt.Errorf("failed to move directory entries: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE files
+		SET directory = $1 || substring(directory FROM char_length($2) + 1), updated_at = NOW()
+		WHERE user_id = $3 AND deleted_at IS NULL AND (directory = $2 OR directory LIKE $4)`,
+		newPath, path, currentUser.ID, pattern,
+	); err != nil {
+		return nil, fmt.Errorf("failed to move directory files: %w", err)
+	}
+
+	if err := ensureDirectoryPath(ctx, tx, currentUser.ID, newPath); err != nil {
+		return nil, fmt.Errorf("failed to record moved directory: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit directory move: %w", err)
+	}
+
+	return directoryByPath(ctx, r.Resolver, currentUser, newPath)
+}
+
+// Trash is a convenience mutation that trashes either a file (fileID) or a
+// directory (directoryPath) without the caller needing to know which
+// dedicated mutation (DeleteFile/DeleteDirectory) applies. Exactly one of
+// fileID or directoryPath must be provided.
+func (r *mutationResolver) Trash(ctx context.Context, fileID *uuid.UUID, directoryPath *string, recursive *bool) (bool, error) {
+	if (fileID == nil) == (directoryPath == nil) {
+		return false, fmt.Errorf("exactly one of fileID or directoryPath must be provided")
+	}
+	if fileID != nil {
+		return r.DeleteFile(ctx, *fileID, nil)
+	}
+	return r.DeleteDirectory(ctx, *directoryPath, recursive)
+}
+
+// RestoreFile takes a trashed file back out of the trash.
+func (r *mutationResolver) RestoreFile(ctx context.Context, id uuid.UUID) (bool, error) {
+	currentUser, err := auth.RequireScope(ctx, "files:write")
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE files SET deleted_at = NULL, purge_storage = FALSE
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
+		id, currentUser.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore file: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check restore: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// RestoreDirectory takes a trashed directory, and any files or
+// subdirectories trashed alongside it, back out of the trash.
+func (r *mutationResolver) RestoreDirectory(ctx context.Context, path string) (bool, error) {
+	currentUser, err := auth.RequireScope(ctx, "directories:write")
+	if err != nil {
+		return false, err
+	}
+
+	pattern := path
+	if !strings.HasSuffix(pattern, "/") {
+		pattern += "/"
+	}
+	pattern += "%"
+
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE directories SET deleted_at = NULL
+		WHERE user_id = $1 AND (path = $2 OR path LIKE $3) AND deleted_at IS NOT NULL`,
+		currentUser.ID, path, pattern)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore directory: %w", err)
+	}
+
+	if _, err := r.DB.ExecContext(ctx, `
+		UPDATE files SET deleted_at = NULL, purge_storage = FALSE
+		WHERE user_id = $1 AND (directory = $2 OR directory LIKE $3) AND deleted_at IS NOT NULL`,
+		currentUser.ID, path, pattern); err != nil {
+		return false, fmt.Errorf("failed to restore directory files: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check restore: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// PurgeTrash immediately hard-deletes every file currently in the current
+// user's trash, regardless of the sweeper's retention period — an explicit
+// "empty trash" action. It releases each file's backing storage object
+// when it was trashed with removeFromStorage (DeleteFile's purge_storage)
+// and nothing else references it, and returns how many files were purged.
+func (r *mutationResolver) PurgeTrash(ctx context.Context) (int, error) {
+	currentUser, err := auth.RequireScope(ctx, "files:delete")
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT id, sha256, purge_storage FROM files WHERE user_id = $1 AND deleted_at IS NOT NULL",
+		currentUser.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trashed files: %w", err)
+	}
+
+	type trashedFile struct {
+		id           uuid.UUID
+		sha256       sql.NullString
+		purgeStorage bool
+	}
+
+	var trashed []trashedFile
+	for rows.Next() {
+		var t trashedFile
+		if err := rows.Scan(&t.id, &t.sha256, &t.purgeStorage); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trashed file: %w", err)
+		}
+		trashed = append(trashed, t)
+	}
+	rows.Close()
+
+	purged := 0
+	for _, t := range trashed {
+		if _, err := r.DB.ExecContext(ctx, "DELETE FROM files WHERE id = $1 AND user_id = $2", t.id, currentUser.ID); err != nil {
+			return purged, fmt.Errorf("failed to purge file %s: %w", t.id, err)
+		}
+
+		if t.purgeStorage && t.sha256.Valid {
+			var refcount int
+			err := r.DB.QueryRowContext(ctx, `
+				UPDATE file_objects SET refcount = refcount - 1
+				WHERE sha256 = $1
+				RETURNING refcount`, t.sha256.String).Scan(&refcount)
+			if err == nil && refcount <= 0 {
+				if err := r.Storage.RemoveObject(t.sha256.String); err == nil {
+					r.DB.ExecContext(ctx, "DELETE FROM file_objects WHERE sha256 = $1", t.sha256.String)
+				}
+			}
+		}
+
+		purged++
+	}
+
+	if _, err := r.DB.ExecContext(ctx,
+		"DELETE FROM directories WHERE user_id = $1 AND deleted_at IS NOT NULL",
+		currentUser.ID); err != nil {
+		return purged, fmt.Errorf("failed to purge trashed directories: %w", err)
+	}
+
+	return purged, nil
+}
+
+// DedupeFiles collapses every file owned by the current user that shares
+// the given fingerprint onto a single physical copy: the oldest matching
+// file's storage_path/telegram_file_id/sha256 become canonical, and every
+// other matching file is repointed at them. Logical file rows are kept;
+// a repointed file's previous content-addressed object (file_objects) is
+// released once its refcount reaches zero, while its previous storage_path
+// file and Telegram copy are left in place. It returns the number of files
+// that were repointed.
+func (r *mutationResolver) DedupeFiles(ctx context.Context, algorithm string, fingerprintValue string) (int, error) {
+	currentUser, err := auth.RequireScope(ctx, "files:write")
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT id, storage_path, telegram_file_id, telegram_file_unique_id, sha256
+		FROM files
+		WHERE user_id = $1 AND fingerprint_algorithm = $2 AND fingerprint = $3 AND deleted_at IS NULL
+		ORDER BY created_at ASC`,
+		currentUser.ID, algorithm, fingerprintValue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query files by fingerprint: %w", err)
+	}
+
+	type duplicate struct {
+		id                   uuid.UUID
+		storagePath          string
+		telegramFileID       sql.NullString
+		telegramFileUniqueID sql.NullString
+		sha256               sql.NullString
+	}
+
+	var dups []duplicate
+	for rows.Next() {
+		var d duplicate
+		if err := rows.Scan(&d.id, &d.storagePath, &d.telegramFileID, &d.telegramFileUniqueID, &d.sha256); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file: %w", err)
+		}
+		dups = append(dups, d)
+	}
+	rows.Close()
+
+	if len(dups) < 2 {
+		return 0, nil
+	}
+
+	canonical := dups[0]
+	deduped := 0
+	for _, d := range dups[1:] {
+		oldSHA256 := d.sha256
+
+		_, err := r.DB.ExecContext(ctx, `
+			UPDATE files SET storage_path = $1, telegram_file_id = $2,
+			telegram_file_unique_id = $3, sha256 = $4, updated_at = NOW()
+			WHERE id = $5 AND user_id = $6`,
+			canonical.storagePath, canonical.telegramFileID, canonical.telegramFileUniqueID, canonical.sha256,
+			d.id, currentUser.ID)
+		if err != nil {
+			return deduped, fmt.Errorf("failed to repoint duplicate file %s: %w", d.id, err)
+		}
+
+		if oldSHA256.Valid && oldSHA256.String != canonical.sha256.String {
+			if canonical.sha256.Valid {
+				if _, err := r.DB.ExecContext(ctx, `
+					UPDATE file_objects SET refcount = refcount + 1
+					WHERE sha256 = $1`, canonical.sha256.String); err != nil {
+					return deduped, fmt.Errorf("failed to bump canonical object refcount: %w", err)
+				}
+			}
+
+			var refcount int
+			err := r.DB.QueryRowContext(ctx, `
+				UPDATE file_objects SET refcount = refcount - 1
+				WHERE sha256 = $1
+				RETURNING refcount`, oldSHA256.String).Scan(&refcount)
+			if err == nil && refcount <= 0 {
+				if err := r.Storage.RemoveObject(oldSHA256.String); err == nil {
+					r.DB.ExecContext(ctx, "DELETE FROM file_objects WHERE sha256 = $1", oldSHA256.String)
+				}
+			}
+		}
+
+		deduped++
+	}
+
+	return deduped, nil
+}
+
+// VerifyIntegrity re-hashes a file's stored bytes using its recorded
+// fingerprint algorithm and reports whether they still match what was
+// stored at creation time. The schema otherwise has no way to detect bit
+// rot or a storage backend silently returning the wrong bytes.
+func (r *mutationResolver) VerifyIntegrity(ctx context.Context, id uuid.UUID) (*model.IntegrityReport, error) {
+	currentUser, err := auth.RequireScope(ctx, "files:read")
+	if err != nil {
+		return nil, err
+	}
+
+	var storagePath string
+	var telegramFileID sql.NullString
+	var fileSize int64
+	var algorithmName, expected sql.NullString
+
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT storage_path, telegram_file_id, file_size, fingerprint_algorithm, fingerprint
+		FROM files WHERE id = $1 AND user_id = $2`,
+		id, currentUser.ID,
+	).Scan(&storagePath, &telegramFileID, &fileSize, &algorithmName, &expected)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up file: %w", err)
+	}
+	if !algorithmName.Valid || !expected.Valid {
+		return nil, fmt.Errorf("file has no recorded fingerprint to verify against")
+	}
+
+	var telegramID *string
+	if telegramFileID.Valid {
+		telegramID = &telegramFileID.String
+	}
+
+	_, actual, err := computeFingerprint(ctx, r.Resolver, algorithmName.String, storagePath, telegramID, fileSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recompute fingerprint: %w", err)
+	}
+
+	return &model.IntegrityReport{
+		FileID:    id,
+		Algorithm: algorithmName.String,
+		Expected:  expected.String,
+		Actual:    actual,
+		Matches:   actual == expected.String,
+	}, nil
+}
+
+// computeFingerprint opens a file's bytes (from local storage if
+// storagePath is set, otherwise from Telegram) and hashes them with
+// algorithmName, defaulting to fingerprint.DefaultAlgorithm when empty.
+func computeFingerprint(ctx context.Context, r *Resolver, algorithmName, storagePath string, telegramFileID *string, fileSize int64) (string, string, error) {
+	if algorithmName == "" {
+		algorithmName = fingerprint.DefaultAlgorithm
+	}
+	algo, ok := fingerprint.Get(algorithmName)
+	if !ok {
+		return "", "", fmt.Errorf("unknown fingerprint algorithm %q", algorithmName)
+	}
+
+	content, err := openFileContent(ctx, r, storagePath, telegramFileID, fileSize)
+	if err != nil {
+		return "", "", err
+	}
+	defer content.Close()
+
+	value, err := algo.Compute(content)
+	if err != nil {
+		return "", "", err
+	}
+
+	return algorithmName, value, nil
+}
+
+// openFileContent opens a file's underlying bytes for (re)hashing,
+// preferring the local copy at storagePath and falling back to fetching
+// the Telegram-backed copy referenced by telegramFileID.
+func openFileContent(ctx context.Context, r *Resolver, storagePath string, telegramFileID *string, fileSize int64) (io.ReadCloser, error) {
+	if storagePath != "" {
+		file, err := os.Open(storagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open stored file: %w", err)
+		}
+		return file, nil
+	}
+
+	if telegramFileID != nil && *telegramFileID != "" {
+		if r.Telegram == nil {
+			return nil, fmt.Errorf("telegram storage is not configured")
+		}
+		info, err := r.Telegram.GetFile(*telegramFileID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve telegram file: %w", err)
+		}
+		return r.Telegram.OpenRange(info.FilePath, 0, info.FileSize-1)
+	}
+
+	return nil, fmt.Errorf("file has no retrievable content")
+}
+
 // Helper function to scan a file from a database row
 func scanFile(scanner interface{ Scan(...interface{}) error }) (*model.File, error) {
 	var file model.File
 	var tags pq.StringArray
 	var telegramFileID, telegramFileUniqueID, description sql.NullString
+	var fingerprintAlgorithm, fingerprintValue sql.NullString
 	var telegramMessageID sql.NullInt64
 
 	err := scanner.Scan(
@@ -617,6 +1119,8 @@ func scanFile(scanner interface{ Scan(...interface{}) error }) (*model.File, err
 		&file.StoragePath,
 		&tags,
 		&description,
+		&fingerprintAlgorithm,
+		&fingerprintValue,
 		&file.CreatedAt,
 		&file.UpdatedAt,
 	)
@@ -638,6 +1142,51 @@ func scanFile(scanner interface{ Scan(...interface{}) error }) (*model.File, err
 	if description.Valid {
 		file.Description = &description.String
 	}
+	if fingerprintAlgorithm.Valid {
+		file.FingerprintAlgorithm = &fingerprintAlgorithm.String
+	}
+	if fingerprintValue.Valid {
+		file.Fingerprint = &fingerprintValue.String
+	}
 
 	return &file, nil
 }
+
+// directoryDepth returns a path's depth in the materialized-path tree: "/"
+// is depth 0, "/a" is depth 1, "/a/b" is depth 2, and so on.
+func directoryDepth(path string) int {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return 0
+	}
+	return strings.Count(trimmed, "/") + 1
+}
+
+// dbExecer is satisfied by both *db.DB and *sqlx.Tx, letting
+// ensureDirectoryPath run either directly or inside a transaction.
+type dbExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ensureDirectoryPath makes sure a directories row, with the correct depth,
+// exists for path and every one of its ancestors, so the materialized-path
+// tree stays authoritative even for directories that were only ever
+// referenced implicitly via a file's directory field.
+func ensureDirectoryPath(ctx context.Context, exec dbExecer, userID string, path string) error {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	current := ""
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		current += "/" + segment
+		_, err := exec.ExecContext(ctx, `
+			INSERT INTO directories (user_id, path, depth) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, path) DO NOTHING`,
+			userID, current, directoryDepth(current))
+		if err != nil {
+			return fmt.Errorf("failed to ensure directory %s exists: %w", current, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aleksandr/lifetrack/backend/auth"
+	"github.com/aleksandr/lifetrack/backend/changefeed"
+	"github.com/aleksandr/lifetrack/backend/graph/model"
+)
+
+// Subscriptions
+
+// pathMatches reports whether eventPath falls under directory, honoring
+// recursive the same way the Directories/DeleteDirectory resolvers treat
+// their own directory-prefix filters.
+func pathMatches(eventPath, directory string, recursive bool) bool {
+	if directory == "" || eventPath == directory {
+		return true
+	}
+	if !recursive {
+		return false
+	}
+	prefix := directory
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.HasPrefix(eventPath, prefix)
+}
+
+// toFileEvent unmarshals event's payload into a FileEvent, the shape the
+// FileChanged subscription streams to clients.
+func toFileEvent(event *changefeed.Event) (*model.FileEvent, error) {
+	var file model.File
+	if err := json.Unmarshal(event.Payload, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal file change-feed event: %w", err)
+	}
+	return &model.FileEvent{
+		Seq:       event.Seq,
+		Operation: string(event.Operation),
+		File:      &file,
+	}, nil
+}
+
+// toDirectoryEvent unmarshals event's payload into a DirectoryEvent, the
+// shape the DirectoryChanged subscription streams to clients.
+func toDirectoryEvent(event *changefeed.Event) (*model.DirectoryEvent, error) {
+	var directory model.Directory
+	if err := json.Unmarshal(event.Payload, &directory); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal directory change-feed event: %w", err)
+	}
+	return &model.DirectoryEvent{
+		Seq:       event.Seq,
+		Operation: string(event.Operation),
+		Directory: &directory,
+	}, nil
+}
+
+// FileChanged streams every CreateFile/UpdateFile/DeleteFile/MoveFile event
+// for the current user, optionally scoped to directory (and its
+// descendants, if recursive). sinceSeq replays whatever the client missed,
+// from the file_events table, before switching over to live delivery.
+func (r *subscriptionResolver) FileChanged(ctx context.Context, directory *string, recursive *bool, sinceSeq *int) (<-chan *model.FileEvent, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	dir := ""
+	if directory != nil {
+		dir = *directory
+	}
+	isRecursive := recursive != nil && *recursive
+
+	events, unsubscribe := r.Changes.Subscribe(currentUser.ID)
+
+	out := make(chan *model.FileEvent, 64)
+	go func() {
+		defer unsubscribe()
+		defer close(out)
+
+		if sinceSeq != nil {
+			missed, err := r.Changes.Since(ctx, currentUser.ID, int64(*sinceSeq))
+			if err != nil {
+				return
+			}
+			for _, event := range missed {
+				if event.EntityType != changefeed.EntityFile || !pathMatches(event.Path, dir, isRecursive) {
+					continue
+				}
+				fileEvent, err := toFileEvent(event)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- fileEvent:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.EntityType != changefeed.EntityFile || !pathMatches(event.Path, dir, isRecursive) {
+					continue
+				}
+				fileEvent, err := toFileEvent(event)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- fileEvent:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// DirectoryChanged streams every CreateDirectory/DeleteDirectory event for
+// the current user, optionally scoped to directory (and its descendants,
+// if recursive). sinceSeq replays whatever the client missed, from the
+// file_events table, before switching over to live delivery.
+func (r *subscriptionResolver) DirectoryChanged(ctx context.Context, directory *string, recursive *bool, sinceSeq *int) (<-chan *model.DirectoryEvent, error) {
+	currentUser, err := auth.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	dir := ""
+	if directory != nil {
+		dir = *directory
+	}
+	isRecursive := recursive != nil && *recursive
+
+	events, unsubscribe := r.Changes.Subscribe(currentUser.ID)
+
+	out := make(chan *model.DirectoryEvent, 64)
+	go func() {
+		defer unsubscribe()
+		defer close(out)
+
+		if sinceSeq != nil {
+			missed, err := r.Changes.Since(ctx, currentUser.ID, int64(*sinceSeq))
+			if err != nil {
+				return
+			}
+			for _, event := range missed {
+				if event.EntityType != changefeed.EntityDirectory || !pathMatches(event.Path, dir, isRecursive) {
+					continue
+				}
+				directoryEvent, err := toDirectoryEvent(event)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- directoryEvent:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.EntityType != changefeed.EntityDirectory || !pathMatches(event.Path, dir, isRecursive) {
+					continue
+				}
+				directoryEvent, err := toDirectoryEvent(event)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- directoryEvent:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
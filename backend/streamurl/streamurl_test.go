@@ -0,0 +1,50 @@
+package streamurl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignAndVerifyRoundTrip(t *testing.T) {
+	signer := NewSigner("test-secret")
+	ts, sig := signer.Sign("file-123", time.Now().Add(time.Hour))
+
+	if err := signer.Verify("file-123", ts, sig); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestVerifyRejectsWrongFileID(t *testing.T) {
+	signer := NewSigner("test-secret")
+	ts, sig := signer.Sign("file-123", time.Now().Add(time.Hour))
+
+	if err := signer.Verify("file-456", ts, sig); err == nil {
+		t.Fatal("Verify() expected error for mismatched file ID, got nil")
+	}
+}
+
+func TestVerifyRejectsTamperedSignature(t *testing.T) {
+	signer := NewSigner("test-secret")
+	ts, sig := signer.Sign("file-123", time.Now().Add(time.Hour))
+
+	if err := signer.Verify("file-123", ts, sig+"00"); err == nil {
+		t.Fatal("Verify() expected error for tampered signature, got nil")
+	}
+}
+
+func TestVerifyRejectsExpiredURL(t *testing.T) {
+	signer := NewSigner("test-secret")
+	ts, sig := signer.Sign("file-123", time.Now().Add(-time.Minute))
+
+	if err := signer.Verify("file-123", ts, sig); err == nil {
+		t.Fatal("Verify() expected error for expired URL, got nil")
+	}
+}
+
+func TestVerifyRejectsDifferentSecret(t *testing.T) {
+	ts, sig := NewSigner("secret-a").Sign("file-123", time.Now().Add(time.Hour))
+
+	if err := NewSigner("secret-b").Verify("file-123", ts, sig); err == nil {
+		t.Fatal("Verify() expected error for signature from a different secret, got nil")
+	}
+}
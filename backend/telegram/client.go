@@ -0,0 +1,172 @@
+// Package telegram is a minimal client for the pieces of the Telegram Bot
+// API that back file storage: resolving a file_id to a downloadable path,
+// fetching byte ranges of that file, and uploading new documents.
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+const defaultBaseURL = "https://api.telegram.org"
+
+// Client talks to the Telegram Bot API using botToken for both the bot
+// (api.telegram.org/bot<token>) and file (api.telegram.org/file/bot<token>)
+// endpoints.
+type Client struct {
+	botToken   string
+	httpClient *http.Client
+	baseURL    string // overridable in tests; defaults to defaultBaseURL
+}
+
+// NewClient creates a Client for the given bot token.
+func NewClient(botToken string) *Client {
+	return &Client{
+		botToken:   botToken,
+		httpClient: http.DefaultClient,
+		baseURL:    defaultBaseURL,
+	}
+}
+
+// FileInfo is the subset of Telegram's getFile response needed to stream a
+// file's bytes.
+type FileInfo struct {
+	FileID   string
+	FilePath string
+	FileSize int64
+}
+
+type getFileResponse struct {
+	OK     bool `json:"ok"`
+	Result struct {
+		FileID   string `json:"file_id"`
+		FilePath string `json:"file_path"`
+		FileSize int64  `json:"file_size"`
+	} `json:"result"`
+	Description string `json:"description"`
+}
+
+// GetFile resolves a file_id (as stored on a files row) to the file_path
+// and size needed to stream its bytes.
+func (c *Client) GetFile(fileID string) (*FileInfo, error) {
+	url := fmt.Sprintf("%s/bot%s/getFile?file_id=%s", c.baseURL, c.botToken, fileID)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call getFile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body getFileResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode getFile response: %w", err)
+	}
+	if !body.OK {
+		return nil, fmt.Errorf("getFile failed: %s", body.Description)
+	}
+
+	return &FileInfo{
+		FileID:   body.Result.FileID,
+		FilePath: body.Result.FilePath,
+		FileSize: body.Result.FileSize,
+	}, nil
+}
+
+// OpenRange streams bytes [start, end] (inclusive) of filePath (as returned
+// by GetFile) from Telegram's file CDN, which honors standard HTTP Range
+// requests, so only the requested bytes are transferred rather than the
+// whole object.
+func (c *Client) OpenRange(filePath string, start, end int64) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s/file/bot%s/%s", c.baseURL, c.botToken, filePath)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build range request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch range: %w", err)
+	}
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("telegram file range request failed: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+type sendDocumentResponse struct {
+	OK     bool `json:"ok"`
+	Result struct {
+		Document struct {
+			FileID       string `json:"file_id"`
+			FileUniqueID string `json:"file_unique_id"`
+			FileSize     int64  `json:"file_size"`
+		} `json:"document"`
+	} `json:"result"`
+	Description string `json:"description"`
+}
+
+// UploadedDocument identifies a document Telegram now stores, usable as the
+// telegram_file_id/telegram_file_unique_id on a files row.
+type UploadedDocument struct {
+	FileID       string
+	FileUniqueID string
+	FileSize     int64
+}
+
+// UploadDocument sends content to chatID via sendDocument and returns the
+// resulting file identifiers.
+func (c *Client) UploadDocument(chatID, filename string, content io.Reader) (*UploadedDocument, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	go func() {
+		defer pw.Close()
+		defer writer.Close()
+
+		if err := writer.WriteField("chat_id", chatID); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		part, err := writer.CreateFormFile("document", filename)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(part, content); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}()
+
+	url := fmt.Sprintf("%s/bot%s/sendDocument", c.baseURL, c.botToken)
+	req, err := http.NewRequest(http.MethodPost, url, pr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sendDocument request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call sendDocument: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body sendDocumentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode sendDocument response: %w", err)
+	}
+	if !body.OK {
+		return nil, fmt.Errorf("sendDocument failed: %s", body.Description)
+	}
+
+	return &UploadedDocument{
+		FileID:       body.Result.Document.FileID,
+		FileUniqueID: body.Result.Document.FileUniqueID,
+		FileSize:     body.Result.Document.FileSize,
+	}, nil
+}
@@ -0,0 +1,104 @@
+package telegram
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withTestServer points a Client at a local httptest.Server instead of
+// api.telegram.org by overriding the base URL the real getFile/sendDocument
+// calls are built against.
+func withTestServer(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &Client{botToken: "test-token", httpClient: server.Client(), baseURL: server.URL}
+}
+
+func TestGetFileParsesResponse(t *testing.T) {
+	client := withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/getFile") {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"ok":true,"result":{"file_id":"abc","file_path":"documents/abc.pdf","file_size":42}}`))
+	})
+
+	info, err := client.GetFile("abc")
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	if info.FilePath != "documents/abc.pdf" || info.FileSize != 42 {
+		t.Fatalf("GetFile() = %+v, unexpected", info)
+	}
+}
+
+func TestGetFileReturnsErrorOnFailure(t *testing.T) {
+	client := withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":false,"description":"file not found"}`))
+	})
+
+	if _, err := client.GetFile("missing"); err == nil {
+		t.Fatal("GetFile() expected error, got nil")
+	}
+}
+
+func TestOpenRangeSendsRangeHeaderAndReturnsBody(t *testing.T) {
+	client := withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Range"); got != "bytes=10-19" {
+			t.Fatalf("Range header = %q, want %q", got, "bytes=10-19")
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("0123456789"))
+	})
+
+	reader, err := client.OpenRange("documents/abc.pdf", 10, 19)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	defer reader.Close()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(got) != "0123456789" {
+		t.Fatalf("OpenRange() body = %q, want %q", got, "0123456789")
+	}
+}
+
+func TestUploadDocumentParsesResponse(t *testing.T) {
+	client := withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/sendDocument") {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm() error = %v", err)
+		}
+		if got := r.FormValue("chat_id"); got != "chat-1" {
+			t.Fatalf("chat_id = %q, want chat-1", got)
+		}
+		file, _, err := r.FormFile("document")
+		if err != nil {
+			t.Fatalf("FormFile() error = %v", err)
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		if string(content) != "hello" {
+			t.Fatalf("uploaded content = %q, want hello", content)
+		}
+
+		w.Write([]byte(`{"ok":true,"result":{"document":{"file_id":"f1","file_unique_id":"u1","file_size":5}}}`))
+	})
+
+	doc, err := client.UploadDocument("chat-1", "hello.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("UploadDocument() error = %v", err)
+	}
+	if doc.FileID != "f1" || doc.FileUniqueID != "u1" || doc.FileSize != 5 {
+		t.Fatalf("UploadDocument() = %+v, unexpected", doc)
+	}
+}
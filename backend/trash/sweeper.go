@@ -0,0 +1,120 @@
+// Package trash hard-deletes files and directories that have been sitting
+// in the trash (files.deleted_at / directories.deleted_at set by the graph
+// resolvers' Trash/DeleteFile/DeleteDirectory mutations) past a configurable
+// retention period. For files trashed with purge_storage set, it also
+// releases their backing storage objects once nothing else references them.
+package trash
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aleksandr/lifetrack/backend/db"
+	"github.com/aleksandr/lifetrack/backend/files"
+)
+
+// Config controls how long trashed rows are kept before Sweeper hard-deletes
+// them, and how often it checks.
+type Config struct {
+	TTL      time.Duration
+	Interval time.Duration
+}
+
+// Sweeper periodically hard-deletes files and directories trashed longer
+// than Config.TTL ago.
+type Sweeper struct {
+	db      *db.DB
+	storage *files.Storage
+	config  Config
+}
+
+// NewSweeper constructs a Sweeper backed by database and storage.
+func NewSweeper(database *db.DB, storage *files.Storage, config Config) *Sweeper {
+	return &Sweeper{db: database, storage: storage, config: config}
+}
+
+// Run sweeps at config.Interval until ctx is cancelled. It's meant to be
+// started in its own goroutine.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed, err := s.Sweep(ctx); err != nil {
+				log.Printf("trash sweeper: %v", err)
+			} else if removed > 0 {
+				log.Printf("trash sweeper: hard-deleted %d file(s)", removed)
+			}
+		}
+	}
+}
+
+// Sweep hard-deletes every file and directory whose deleted_at is older
+// than config.TTL and returns how many files were removed. For files
+// trashed with purge_storage set, it decrements file_objects.refcount and
+// unlinks the backing object once nothing references it; other files'
+// objects are left as they are.
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-s.config.TTL)
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, sha256, purge_storage FROM files WHERE deleted_at IS NOT NULL AND deleted_at <= $1",
+		cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trashed files: %w", err)
+	}
+
+	type trashedFile struct {
+		id           string
+		sha256       sql.NullString
+		purgeStorage bool
+	}
+
+	var trashed []trashedFile
+	for rows.Next() {
+		var t trashedFile
+		if err := rows.Scan(&t.id, &t.sha256, &t.purgeStorage); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trashed file: %w", err)
+		}
+		trashed = append(trashed, t)
+	}
+	rows.Close()
+
+	removed := 0
+	for _, t := range trashed {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM files WHERE id = $1", t.id); err != nil {
+			return removed, fmt.Errorf("failed to hard-delete file %s: %w", t.id, err)
+		}
+
+		if t.purgeStorage && t.sha256.Valid {
+			var refcount int
+			err := s.db.QueryRowContext(ctx, `
+				UPDATE file_objects SET refcount = refcount - 1
+				WHERE sha256 = $1
+				RETURNING refcount`, t.sha256.String).Scan(&refcount)
+			if err == nil && refcount <= 0 {
+				if err := s.storage.RemoveObject(t.sha256.String); err == nil {
+					s.db.ExecContext(ctx, "DELETE FROM file_objects WHERE sha256 = $1", t.sha256.String)
+				}
+			}
+		}
+
+		removed++
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		"DELETE FROM directories WHERE deleted_at IS NOT NULL AND deleted_at <= $1", cutoff,
+	); err != nil {
+		return removed, fmt.Errorf("failed to hard-delete trashed directories: %w", err)
+	}
+
+	return removed, nil
+}